@@ -0,0 +1,51 @@
+package ffs
+
+import (
+	"bytes"
+)
+
+// WithReadCache makes ReadFile cache the contents it returns, so that
+// repeated reads of an unchanged file skip decoding it again (see
+// WithTransparentGzip and WithTextMode).
+// Each call still returns its own copy of the cached contents, so only the
+// decoding is saved, not the allocation: files that need no decoding are read
+// just as fast without the cache.
+// An entry is invalidated by any change to the file's contents (writes,
+// truncation), or when the path no longer refers to the same file (e.g.,
+// after it's removed or replaced).
+// Meant for benchmarks that read the same fixtures over and over.
+func WithReadCache() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.readCache = map[string]readCacheEntry{}
+	}
+}
+
+type readCacheEntry struct {
+	file *FakeFile
+	gen  uint64 // generation of the file's contents when cached
+	data []byte
+}
+
+// readFileCached is ReadFile with the read cache enabled.
+func (m *FakeFileSystem) readFileCached(uncleanedPath string) ([]byte, error) {
+	path := m.abs(uncleanedPath)
+	f, err := m.lookup(uncleanedPath)
+	if err == nil {
+		if e, ok := m.readCache[path]; ok && e.file == f && e.gen == f.gen {
+			m.accessed(f)
+			return bytes.Clone(e.data), nil
+		}
+	}
+	bs, err := m.readFileNoCopy(uncleanedPath)
+	if err != nil {
+		delete(m.readCache, path)
+		return nil, err
+	}
+	data := bytes.Clone(bs)
+	m.readCache[path] = readCacheEntry{
+		file: f,
+		gen:  f.gen,
+		data: data,
+	}
+	return bytes.Clone(data), nil
+}
@@ -0,0 +1,125 @@
+package ffs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadCache(t *testing.T) {
+	m := MockFS(
+		WithReadCache(),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	bs1, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs2, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs1) != testContent || string(bs2) != testContent {
+		t.Errorf("got: `%s' and `%s', want: `%s'", bs1, bs2, testContent)
+	}
+	if &bs1[0] == &bs2[0] {
+		t.Error("expected each call to return its own copy")
+	}
+	bs1[0] = 'X'
+	bs3, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs3) != testContent {
+		t.Errorf("modifying a returned slice changed the cache: got: `%s', want: `%s'", bs3, testContent)
+	}
+	bs1[0] = testContent[0]
+
+	const newContent = "Giraffe > Greif"
+	err = m.WriteFile(testFilePath, []byte(newContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != newContent {
+		t.Errorf("got: `%s', want: `%s'", bs, newContent)
+	}
+	// the previously returned slice is unaffected
+	if string(bs1) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs1, testContent)
+	}
+
+	err = m.Truncate(testFilePath, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != newContent[:3] {
+		t.Errorf("got: `%s', want: `%s'", bs, newContent[:3])
+	}
+
+	fd, err := m.Create(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	err = m.Remove(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.ReadFile(testFilePath)
+	if err == nil {
+		t.Error("expected an error reading a removed file")
+	}
+	err = m.WriteFile(testFilePath, []byte(newContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != newContent {
+		t.Errorf("got: `%s', want: `%s'", bs, newContent)
+	}
+}
+
+// benchmarkReadFileGzip reads a compressed fixture, which has to be
+// decompressed on every read, unless it's cached.
+func benchmarkReadFileGzip(b *testing.B, opts ...FSOption) {
+	m := MockFS(append(opts,
+		WithTransparentGzip(".gz"),
+		WithFile("/fixtures/data.txt.gz", gzipBytes(bytes.Repeat([]byte(testContent), 1<<14))),
+	)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.ReadFile("/fixtures/data.txt.gz"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFileGzip(b *testing.B) {
+	benchmarkReadFileGzip(b)
+}
+
+func BenchmarkReadFileGzipCached(b *testing.B) {
+	benchmarkReadFileGzip(b, WithReadCache())
+}
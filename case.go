@@ -0,0 +1,63 @@
+package ffs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// WithCaseInsensitive makes file names case-insensitive, like on the default
+// volumes of macOS and Windows: /Foo and /foo name the same file. Names are
+// case-preserving, a file is listed with the name it was created with, and
+// a case-only rename (Rename("/Foo", "/foo")) changes just that name.
+func WithCaseInsensitive() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.caseInsensitive = true
+	}
+}
+
+// fold replaces the components of the absolute, clean path with the names
+// of the existing files they match case-insensitively. Components that don't
+// match a file (because it doesn't exist yet, or is below a symlink) are
+// kept as they are.
+func (m *FakeFileSystem) fold(path string) string {
+	cur := m.root
+	folded := "/"
+	for _, c := range strings.Split(path, "/")[1:] {
+		if c == "" {
+			continue // path is "/"
+		}
+		var next *FakeFile
+		if cur != nil && cur.isDir {
+			next = cur.childFold(c)
+		}
+		if next != nil {
+			c = next.name
+		}
+		folded = filepath.Join(folded, c)
+		cur = next
+	}
+	return folded
+}
+
+// childFold returns the child of the directory d whose name matches name
+// case-insensitively, or nil.
+func (d *FakeFile) childFold(name string) *FakeFile {
+	for _, c := range d.children {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// childPath returns the path of the entry name in the directory p. On a
+// case-insensitive file system, that's the path of an existing child that
+// matches name, if there is one.
+func (m *FakeFileSystem) childPath(p *FakeFile, name string) string {
+	if m.caseInsensitive {
+		if c := p.childFold(name); c != nil {
+			return c.path
+		}
+	}
+	return filepath.Join(p.path, name)
+}
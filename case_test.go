@@ -0,0 +1,85 @@
+package ffs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	m := MockFS(
+		WithCaseInsensitive(),
+		WithFile("/Dir/File.txt", []byte(testContent)),
+	)
+	bs, err := m.ReadFile("/dir/FILE.TXT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	// writing through another spelling doesn't create a second file
+	if err := m.WriteFile("/DIR/file.txt", []byte("changed"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := m.ReadDir("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "File.txt" {
+		t.Errorf("got: `%v', want: only `File.txt'", entries)
+	}
+	if err := m.Mkdir("/dir", 0755); !errors.Is(err, syscall.EEXIST) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EEXIST)
+	}
+	if err := m.Symlink("/dir/file.txt", "/link"); err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile("/LINK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "changed" {
+		t.Errorf("got: `%s', want: `changed'", bs)
+	}
+}
+
+func TestCaseOnlyRename(t *testing.T) {
+	m := MockFS(
+		WithCaseInsensitive(),
+		WithFile("/Foo", []byte(testContent)),
+	)
+	if err := m.Rename("/Foo", "/foo"); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat("/FOO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "foo" {
+		t.Errorf("got: `%s', want: `foo'", fi.Name())
+	}
+	entries, err := m.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "foo" {
+		t.Errorf("got: `%v', want: only `foo'", entries)
+	}
+	bs, err := m.ReadFile("/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestCaseSensitiveByDefault(t *testing.T) {
+	m := MockFS(
+		WithFile("/Foo", []byte(testContent)),
+	)
+	if _, err := m.Stat("/foo"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
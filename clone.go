@@ -0,0 +1,97 @@
+package ffs
+
+import (
+	"bytes"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Clone returns a fully independent copy of the file system: the tree is
+// copied node by node, including the contents of the files, so that
+// mutating either one never affects the other.
+// The options the file system was created with carry over, as do injected
+// errors, but state tied to open descriptors (locks, removed files that are
+// still open, the open file count) doesn't, and the read cache starts out
+// empty.
+func (m *FakeFileSystem) Clone() *FakeFileSystem {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := &FakeFileSystem{
+		cwd:             m.cwd,
+		contents:        map[string]*FakeFile{},
+		transparentGzip: m.transparentGzip,
+		gzipSuffix:      m.gzipSuffix,
+		crashSim:        m.crashSim,
+		syncOnWriteFile: m.syncOnWriteFile,
+		durableOnClose:  m.durableOnClose,
+		newFileTemplate: bytes.Clone(m.newFileTemplate),
+		busy:            maps.Clone(m.busy),
+		executing:       maps.Clone(m.executing),
+		maxOpenFiles:    m.maxOpenFiles,
+		textMode:        m.textMode,
+		lineEnding:      m.lineEnding,
+		noAtime:         m.noAtime,
+		tempCount:       m.tempCount,
+		capacity:        m.capacity,
+		readOnly:        m.readOnly,
+		caseInsensitive: m.caseInsensitive,
+		enforcePerms:    m.enforcePerms,
+		uid:             m.uid,
+		gid:             m.gid,
+	}
+	if m.readCache != nil {
+		c.readCache = map[string]readCacheEntry{}
+	}
+	m.injectMu.Lock()
+	c.injections = slices.Clone(m.injections)
+	m.injectMu.Unlock()
+	c.root = c.cloneFile(m.root, nil, map[*inode]*inode{})
+	c.parent = c.root
+	return c
+}
+
+// cloneFile copies f and all of its descendants into c, attaching the copy
+// to parent. Hard links stay hard links: names sharing an inode in the
+// original share the copy of it, as recorded in inodes.
+func (c *FakeFileSystem) cloneFile(f *FakeFile, parent *FakeFile, inodes map[*inode]*inode) *FakeFile {
+	ino, ok := inodes[f.inode]
+	if !ok {
+		ino = &inode{
+			bytes:      bytes.Clone(f.bytes),
+			mode:       f.mode,
+			lastMod:    f.lastMod,
+			lastChange: f.lastChange,
+			lastAccess: f.lastAccess,
+			durable:    bytes.Clone(f.durable),
+			gen:        f.gen,
+			nlink:      f.nlink,
+			uid:        f.uid,
+			gid:        f.gid,
+		}
+		if f.xattrs != nil {
+			ino.xattrs = map[string][]byte{}
+			for k, v := range f.xattrs {
+				ino.xattrs[k] = bytes.Clone(v)
+			}
+		}
+		inodes[f.inode] = ino
+	}
+	n := &FakeFile{
+		inode:      ino,
+		isDir:      f.isDir,
+		path:       f.path,
+		name:       f.name,
+		symlink:    f.symlink,
+		linkTarget: f.linkTarget,
+		parent:     parent,
+	}
+	if f.isDir {
+		n.children = map[string]*FakeFile{}
+		for k, child := range f.children {
+			n.children[k] = c.cloneFile(child, n, inodes)
+		}
+	}
+	c.contents[n.path] = n
+	return n
+}
@@ -0,0 +1,65 @@
+package ffs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	c := m.Clone()
+
+	// write through a descriptor, so the bytes are modified in place
+	fd, err := c.OpenFile(testFilePath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("XXXX")); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if err := c.WriteFile("/new", []byte(testContent), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveAll(testFileDir); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	if _, err := m.Stat("/new"); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+	if _, err := c.Stat(testFilePath); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	c := m.Clone()
+	for path, f := range c.contents {
+		o := m.contents[path]
+		if o == f {
+			t.Errorf("%s: node is shared", path)
+		}
+		if f.parent != nil && c.contents[f.parent.path] != f.parent {
+			t.Errorf("%s: parent points into the original", path)
+		}
+		if len(f.bytes) > 0 && &f.bytes[0] == &o.bytes[0] {
+			t.Errorf("%s: bytes are aliased", path)
+		}
+	}
+	if len(c.contents) != len(m.contents) {
+		t.Errorf("got: %d files, want: %d", len(c.contents), len(m.contents))
+	}
+}
@@ -0,0 +1,210 @@
+package ffs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race to detect unsynchronized accesses.
+func TestConcurrentAccess(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	const workers, rounds = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*workers)
+	for i := 0; i < workers; i++ {
+		path := fmt.Sprintf("/Classified/%d.txt", i)
+		wg.Add(4)
+		go func() { // writer through the file system
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if err := m.WriteFile(testFilePath, []byte(testContent), testPerm); err != nil {
+					errs <- err
+					return
+				}
+				if err := m.Truncate(testFilePath, 4); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() { // writer through a descriptor
+			defer wg.Done()
+			fd, err := m.Create(path)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer fd.Close()
+			for j := 0; j < rounds; j++ {
+				if _, err := fd.Write([]byte(testContent)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := fd.Seek(0, io.SeekStart); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() { // reader through the file system
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if _, err := m.ReadFile(testFilePath); err != nil {
+					errs <- err
+					return
+				}
+				fi, err := m.Stat(testFilePath)
+				if err != nil {
+					errs <- err
+					return
+				}
+				_ = fi.Size()
+				err = m.WalkDir(testFileDir, func(path string, d fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					_, err = d.Info()
+					return err
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+				entries, err := m.ReadDir(testFileDir)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, e := range entries {
+					fi, err := e.Info()
+					if err != nil {
+						errs <- err
+						return
+					}
+					_ = fi.Size()
+				}
+			}
+		}()
+		go func() { // reader through a descriptor
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := io.ReadAll(fd); err != nil {
+					errs <- err
+				}
+				if _, err := fd.Stat(); err != nil {
+					errs <- err
+				}
+				fd.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// Run with -race to detect unsynchronized accesses.
+func TestConcurrentRemove(t *testing.T) {
+	const files = 50
+	opts := []FSOption{WithDirectory(testFileDir)}
+	for i := 0; i < files; i++ {
+		opts = append(opts, WithFile(fmt.Sprintf("/Classified/%d.txt", i), []byte(testContent)))
+	}
+	m := MockFS(opts...)
+
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		var lastUsage int64 = files * int64(len(testContent))
+		var lastMod time.Time
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			usage := m.Usage()
+			if usage > lastUsage || usage%int64(len(testContent)) != 0 {
+				errs <- fmt.Errorf("usage went from %d to %d", lastUsage, usage)
+				return
+			}
+			lastUsage = usage
+			fi, err := m.Stat(testFileDir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if fi.ModTime().Before(lastMod) {
+				errs <- fmt.Errorf("directory mtime went back from %v to %v", lastMod, fi.ModTime())
+				return
+			}
+			lastMod = fi.ModTime()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < files; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.Remove(fmt.Sprintf("/Classified/%d.txt", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(done)
+	for err := range errs {
+		t.Error(err)
+	}
+	if usage := m.Usage(); usage != 0 {
+		t.Errorf("got usage: %d, want: 0", usage)
+	}
+}
+
+// Run with -race to detect unsynchronized accesses.
+func TestConcurrentDescriptorInfo(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	ffd := fd.(*FakeFileDescriptor)
+	const rounds = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { // writer
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if _, err := fd.Write([]byte(testContent)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() { // reader of the descriptor's metadata
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			_, _, _, _ = ffd.Size(), ffd.ModTime(), ffd.Mode(), ffd.Sys()
+		}
+	}()
+	wg.Wait()
+}
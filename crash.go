@@ -0,0 +1,92 @@
+package ffs
+
+// WithCrashSimulation distinguishes between buffered and durable file
+// contents.
+// Writes only modify the buffered contents of a file, until they are made
+// durable by a call to Sync, either of the whole file system, or of a
+// descriptor of the file. Crash discards everything that hasn't been made
+// durable yet.
+// The initial contents of the file system (as set up by the other options)
+// start out durable. Changes to the directory structure (creating and
+// removing files) are always considered durable, only file contents are
+// buffered.
+func WithCrashSimulation() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.crashSim = true
+	}
+}
+
+// WithSyncOnWriteFile makes the data written by WriteFile durable right away,
+// as if WriteFile were followed by a Sync of the file.
+// Writes through descriptors stay buffered.
+func WithSyncOnWriteFile() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.syncOnWriteFile = true
+	}
+}
+
+// WithDurableOnClose makes closing a descriptor that was opened for writing
+// promote the file's buffered contents to durable, as if the descriptor were
+// synced before being closed.
+// Without it, only an explicit Sync makes writes durable.
+func WithDurableOnClose() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.durableOnClose = true
+	}
+}
+
+// Sync makes the buffered contents of every file durable, modeling sync(2).
+// Without crash simulation, this does nothing.
+func (m *FakeFileSystem) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.crashSim {
+		return nil
+	}
+	for _, f := range m.contents {
+		f.sync()
+	}
+	return nil
+}
+
+// Crash simulates a system crash: the contents of every file are reset to
+// what was last made durable.
+// Without crash simulation, all writes are durable and this does nothing.
+func (m *FakeFileSystem) Crash() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.crashSim {
+		return
+	}
+	for _, f := range m.contents {
+		if f.isDir {
+			continue
+		}
+		f.bytes = append([]byte(nil), f.durable...)
+		f.gen++
+	}
+}
+
+// sync makes the current contents of f durable.
+func (f *FakeFile) sync() {
+	if f.isDir {
+		return
+	}
+	f.durable = append([]byte(nil), f.bytes...)
+}
+
+// syncWriteFile makes the contents of f durable after a WriteFile, if
+// configured to do so.
+func (m *FakeFileSystem) syncWriteFile(f *FakeFile) {
+	if m.crashSim && m.syncOnWriteFile {
+		f.sync()
+	}
+}
+
+// syncClose makes the contents of the file behind fd durable when it's
+// closed, if configured to do so.
+func (m *FakeFileSystem) syncClose(fd *FakeFileDescriptor) {
+	if m.crashSim && m.durableOnClose && canWrite(fd.flag) {
+		fd.file.sync()
+	}
+}
@@ -0,0 +1,185 @@
+package ffs
+
+import (
+	"testing"
+)
+
+func TestCrashSimulationSync(t *testing.T) {
+	m := MockFS(
+		WithCrashSimulation(),
+		WithFile("/data/a", []byte("a")),
+		WithFile("/data/b", []byte("b")),
+		WithDirectory("/data/c"),
+	)
+	for _, path := range []string{"/data/a", "/data/b", "/data/c/d"} {
+		fd, err := m.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fd.Write([]byte(testContent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = fd.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := m.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Crash()
+
+	for _, path := range []string{"/data/a", "/data/b", "/data/c/d"} {
+		bs, err := m.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != testContent {
+			t.Errorf("%s: got: `%s', want: `%s'", path, bs, testContent)
+		}
+	}
+}
+
+func TestCrashSimulationLosesUnsynced(t *testing.T) {
+	m := MockFS(
+		WithCrashSimulation(),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Create(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte("Giraffe > Greif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Crash()
+
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestCrashSimulationSyncOnWriteFile(t *testing.T) {
+	for _, syncOnWriteFile := range []bool{true, false} {
+		opts := []FSOption{
+			WithCrashSimulation(),
+			WithFile(testFilePath, []byte(testContent)),
+		}
+		if syncOnWriteFile {
+			opts = append(opts, WithSyncOnWriteFile())
+		}
+		m := MockFS(opts...)
+		const newContent, newPath = "Giraffe > Greif", "/Classified/New.txt"
+		for _, path := range []string{testFilePath, newPath} {
+			err := m.WriteFile(path, []byte(newContent), testPerm)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		m.Crash()
+
+		expected := map[string]string{testFilePath: testContent, newPath: ""}
+		if syncOnWriteFile {
+			expected = map[string]string{testFilePath: newContent, newPath: newContent}
+		}
+		for path, want := range expected {
+			bs, err := m.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != want {
+				t.Errorf("sync on WriteFile: %t: %s: got: `%s', want: `%s'", syncOnWriteFile, path, bs, want)
+			}
+		}
+	}
+}
+
+func TestCrashSimulationDurableOnClose(t *testing.T) {
+	for _, durableOnClose := range []bool{true, false} {
+		opts := []FSOption{
+			WithCrashSimulation(),
+			WithFile(testFilePath, []byte(testContent)),
+		}
+		if durableOnClose {
+			opts = append(opts, WithDurableOnClose())
+		}
+		m := MockFS(opts...)
+		const newContent = "Giraffe > Greif"
+		fd, err := m.Create(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fd.Write([]byte(newContent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = fd.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m.Crash()
+
+		want := testContent
+		if durableOnClose {
+			want = newContent
+		}
+		bs, err := m.ReadFile(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("durable on close: %t: got: `%s', want: `%s'", durableOnClose, bs, want)
+		}
+	}
+}
+
+func TestCrashSimulationFileSync(t *testing.T) {
+	m := MockFS(
+		WithCrashSimulation(),
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/other", []byte(testContent)),
+	)
+	const newContent = "Giraffe > Greif"
+	for _, path := range []string{testFilePath, "/other"} {
+		fd, err := m.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fd.Write([]byte(newContent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only the first file is synced
+		if path == testFilePath {
+			if err := fd.Sync(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fd.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Crash()
+
+	for path, want := range map[string]string{testFilePath: newContent, "/other": testContent} {
+		bs, err := m.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != want {
+			t.Errorf("%s: got: `%s', want: `%s'", path, bs, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ffs
+
+import (
+	"bytes"
+	"sort"
+)
+
+// ChangeKind tells how a path differs between two file systems, see Diff.
+type ChangeKind int
+
+const (
+	Added    ChangeKind = iota // only exists in b
+	Removed                    // only exists in a
+	Modified                   // exists in both, but differs
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a difference between two file systems, see Diff.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	// OldSize and NewSize are the sizes of the file in a and b, they are
+	// only set for Modified changes.
+	OldSize, NewSize int64
+}
+
+// Diff reports the paths that differ between a and b, sorted by path.
+// A path is Modified if its contents (for symlinks, its target) or its mode
+// (including the type) differ; timestamps are not compared. Every path of
+// an added or removed directory is reported, not just the directory itself.
+func Diff(a, b *FakeFileSystem) []Change {
+	if a == b {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var changes []Change
+	for path, af := range a.contents {
+		bf, ok := b.contents[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, Kind: Removed})
+		case af.fileMode() != bf.fileMode() ||
+			af.linkTarget != bf.linkTarget ||
+			!bytes.Equal(af.bytes, bf.bytes):
+			changes = append(changes, Change{
+				Path:    path,
+				Kind:    Modified,
+				OldSize: af.size(),
+				NewSize: bf.size(),
+			})
+		}
+	}
+	for path := range b.contents {
+		if _, ok := a.contents[path]; !ok {
+			changes = append(changes, Change{Path: path, Kind: Added})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
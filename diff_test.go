@@ -0,0 +1,50 @@
+package ffs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := MockFS(
+		WithFile("/removed.txt", []byte(testContent)),
+		WithFile("/edited.txt", []byte(testContent)),
+		WithFile("/same.txt", []byte(testContent)),
+		WithFile("/chmod.txt", []byte(testContent)),
+	)
+	b := a.Clone()
+	if err := b.Remove("/removed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.MkdirAll("/added", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteFile("/added/new.txt", nil, testPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteFile("/edited.txt", []byte("edited"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Chmod("/chmod.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+	// only timestamps change
+	if err := b.WriteFile("/same.txt", []byte(testContent), testPerm); err != nil {
+		t.Fatal(err)
+	}
+
+	size := int64(len(testContent))
+	want := []Change{
+		{Path: "/added", Kind: Added},
+		{Path: "/added/new.txt", Kind: Added},
+		{Path: "/chmod.txt", Kind: Modified, OldSize: size, NewSize: size},
+		{Path: "/edited.txt", Kind: Modified, OldSize: size, NewSize: int64(len("edited"))},
+		{Path: "/removed.txt", Kind: Removed},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: `%v', want: `%v'", got, want)
+	}
+	if got := Diff(a, a.Clone()); len(got) != 0 {
+		t.Errorf("got: `%v', want: no changes", got)
+	}
+}
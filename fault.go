@@ -0,0 +1,86 @@
+package ffs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// injection is an error injected by InjectError or InjectErrorOnce.
+type injection struct {
+	op, pattern string
+	err         error
+	once        bool
+}
+
+// InjectError makes every call of the operation op on a path matching
+// pattern fail with err, before it has any effect.
+// The operation is named by its method, e.g. "WriteFile", "OpenFile", or
+// "Read" (on descriptors); an empty op matches every operation.
+// The pattern is matched against the absolute path, using the syntax of
+// filepath.Match. The error is wrapped in an *os.PathError (or *os.LinkError,
+// for Rename and Symlink), like any other error of the operation.
+func (m *FakeFileSystem) InjectError(op, pattern string, err error) {
+	m.injectMu.Lock()
+	defer m.injectMu.Unlock()
+	m.injections = append(m.injections, injection{op, pattern, err, false})
+}
+
+// InjectErrorOnce is like InjectError, but only the next matching call
+// fails.
+func (m *FakeFileSystem) InjectErrorOnce(op, pattern string, err error) {
+	m.injectMu.Lock()
+	defer m.injectMu.Unlock()
+	m.injections = append(m.injections, injection{op, pattern, err, true})
+}
+
+// ClearErrors removes all injected errors.
+func (m *FakeFileSystem) ClearErrors() {
+	m.injectMu.Lock()
+	defer m.injectMu.Unlock()
+	m.injections = nil
+}
+
+// injected returns the error injected for the operation op on path, if any.
+// The caller must hold mu (for reading, at least).
+func (m *FakeFileSystem) injected(op, path string) error {
+	m.injectMu.Lock()
+	defer m.injectMu.Unlock()
+	if len(m.injections) == 0 {
+		return nil
+	}
+	abs := m.abs(path)
+	for i, inj := range m.injections {
+		if inj.op != "" && inj.op != op {
+			continue
+		}
+		if ok, _ := filepath.Match(inj.pattern, abs); !ok {
+			continue
+		}
+		if inj.once {
+			m.injections = append(m.injections[:i:i], m.injections[i+1:]...)
+		}
+		return inj.err
+	}
+	return nil
+}
+
+// injectedPathError is injected, wrapped in an *os.PathError.
+func (m *FakeFileSystem) injectedPathError(op, pathOp, path string) error {
+	if err := m.injected(op, path); err != nil {
+		return &os.PathError{
+			Op:   pathOp,
+			Path: path,
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+// injected returns the error injected for the operation op on this
+// descriptor's file, if any.
+func (m *FakeFileDescriptor) injected(op, pathOp string) error {
+	if m.fsys == nil {
+		return nil
+	}
+	return m.fsys.injectedPathError(op, pathOp, m.file.path)
+}
@@ -0,0 +1,106 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestInjectErrorWriteFile(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	m.InjectError("WriteFile", "/Classified/*", syscall.ENOSPC)
+	for i := 0; i < 2; i++ {
+		err := m.WriteFile(testFilePath, []byte("Giraffe > Greif"), testPerm)
+		if !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+		}
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) || pathErr.Op != "open" || pathErr.Path != testFilePath {
+			t.Errorf("got: `%#v', want: *os.PathError{Op: open, Path: %s}", err, testFilePath)
+		}
+	}
+	// the injected error fires before the file is touched
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	// other paths are unaffected
+	if err := m.WriteFile("/other", []byte(testContent), testPerm); err != nil {
+		t.Error(err)
+	}
+
+	m.ClearErrors()
+	if err := m.WriteFile(testFilePath, []byte(testContent), testPerm); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInjectErrorOnce(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	m.InjectErrorOnce("", testFilePath, syscall.EACCES)
+	_, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if !errors.Is(err, syscall.EACCES) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EACCES)
+	}
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+}
+
+func TestInjectErrorDescriptor(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	m.InjectError("Read", testFilePath, syscall.EIO)
+	m.InjectError("Write", testFilePath, syscall.ENOSPC)
+	_, err = fd.Read(make([]byte, 4))
+	if !errors.Is(err, syscall.EIO) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EIO)
+	}
+	_, err = fd.Write([]byte("1234"))
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	// only the named operations fail
+	if _, err := fd.ReadAt(make([]byte, 4), 0); err != nil {
+		t.Error(err)
+	}
+	if _, err := fd.Stat(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInjectErrorTruncateAtomic(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, make([]byte, 100)),
+	)
+	m.InjectError("Truncate", testFilePath, syscall.EIO)
+	for _, size := range []int64{10, 0, 200} {
+		err := m.Truncate(testFilePath, size)
+		if !errors.Is(err, syscall.EIO) {
+			t.Errorf("got: `%v', want: `%v'", err, syscall.EIO)
+		}
+		fi, err := m.Stat(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != 100 {
+			t.Errorf("got: %d, want: 100", fi.Size())
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,25 +21,37 @@ import (
 
 // Time tells the "current" time.
 // For testing purposes, you can reassign this variable:
-// 	ffs.Time = func() (t time.Time) {
-// 		// always return zero value
-// 		return
-// 	}
+//
+//	ffs.Time = func() (t time.Time) {
+//		// always return zero value
+//		return
+//	}
 var Time = time.Now
 
 type FileSystem interface {
 	Create(path string) (File, error)
 	Open(path string) (File, error)
 	Stat(path string) (os.FileInfo, error)
+	Lstat(path string) (os.FileInfo, error)
 	OpenFile(path string, flag int, perm fs.FileMode) (File, error)
-	// @todo: Mkdir(name string, perm FileMode) error
-	// @todo: MkdirAll(path string, perm FileMode) error
+	Mkdir(path string, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	ReadDir(path string) ([]fs.DirEntry, error)
 	WalkDir(root string, fn fs.WalkDirFunc) error
 	Truncate(path string, size int64) error
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data []byte, perm os.FileMode) error
 	Remove(path string) error
 	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Chmod(path string, mode fs.FileMode) error
+	Chtimes(path string, atime, mtime time.Time) error
+	CreateTemp(dir, pattern string) (File, error)
+	MkdirTemp(dir, pattern string) (string, error)
+	Glob(pattern string) ([]string, error)
+	Link(oldname, newname string) error
 }
 
 type File interface {
@@ -47,8 +61,19 @@ type File interface {
 	Read(b []byte) (n int, err error)                     // go doc os.File.Read
 	Write(b []byte) (n int, err error)                    // go doc os.File.Write
 	Seek(offset int64, whence int) (ret int64, err error) // go doc os.File.Seek
+	ReadAt(b []byte, off int64) (n int, err error)        // go doc os.File.ReadAt
+	WriteAt(b []byte, off int64) (n int, err error)       // go doc os.File.WriteAt
+	Sync() error                                          // go doc os.File.Sync
 }
 
+// Fder is implemented by files that expose a file descriptor, like
+// *os.File.
+type Fder interface {
+	Fd() uintptr
+}
+
+var _ Fder = (*os.File)(nil)
+
 type RealFileSystem struct{}
 
 var _ FileSystem = (*RealFileSystem)(nil)
@@ -65,10 +90,26 @@ func (*RealFileSystem) Stat(path string) (fs.FileInfo, error) {
 	return os.Stat(path)
 }
 
+func (*RealFileSystem) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
 func (*RealFileSystem) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
 	return os.OpenFile(path, flag, perm)
 }
 
+func (*RealFileSystem) Mkdir(path string, perm fs.FileMode) error {
+	return os.Mkdir(path, perm)
+}
+
+func (*RealFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (*RealFileSystem) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
 func (*RealFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
 	return filepath.WalkDir(root, fn)
 }
@@ -93,9 +134,78 @@ func (*RealFileSystem) RemoveAll(path string) error {
 	return os.RemoveAll(path)
 }
 
+func (*RealFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (*RealFileSystem) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (*RealFileSystem) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (*RealFileSystem) Chmod(path string, mode fs.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+func (*RealFileSystem) Chtimes(path string, atime, mtime time.Time) error {
+	return os.Chtimes(path, atime, mtime)
+}
+
+func (*RealFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (*RealFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+func (*RealFileSystem) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (*RealFileSystem) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
 type FakeFileSystem struct {
+	// mu guards the whole tree, including the contents of the files.
+	// Descriptors opened through the file system synchronize on it too.
+	// FileInfos and DirEntries are snapshots taken under the lock.
+	mu           sync.RWMutex
 	parent, root *FakeFile
 	contents     map[string]*FakeFile
+	cwd          string // working directory, relative paths are resolved against it
+
+	transparentGzip bool
+	gzipSuffix      string
+	crashSim        bool
+	syncOnWriteFile bool
+	durableOnClose  bool
+	newFileTemplate []byte
+	busy            map[string]bool
+	executing       map[string]bool
+	maxOpenFiles    int
+	openFiles       int
+	textMode        bool
+	lineEnding      string
+	readCache       map[string]readCacheEntry
+	noAtime         bool
+	tempCount       uint64     // see CreateTemp and MkdirTemp
+	injectMu        sync.Mutex // guards injections, which even readers consume
+	injections      []injection
+	capacity        int64           // see WithCapacity
+	orphans         map[*inode]bool // removed, but still open
+	readOnly        bool            // see WithReadOnly
+	flockCond       *sync.Cond      // see Flock
+	caseInsensitive bool            // see WithCaseInsensitive
+	recordMu        sync.Mutex      // guards recording and operations, see Record
+	recording       bool
+	operations      []Operation
+	enforcePerms    bool // see WithUser
+	uid, gid        int  // the acting user, see WithUser
 }
 
 var _ FileSystem = (*FakeFileSystem)(nil)
@@ -104,9 +214,9 @@ var _ FileSystem = (*FakeFileSystem)(nil)
 const umask = 0022
 
 func (m *FakeFileSystem) createFile(uncleanedPath string, flag int, perm fs.FileMode) (File, error) {
-	path := filepath.Clean(uncleanedPath)
+	path := m.abs(uncleanedPath)
 
-	if f, ok := m.contents[path]; ok {
+	if f, err := m.lookup(uncleanedPath); err == nil {
 		if f.isDir {
 			return nil, &os.PathError{
 				Op:   "open",
@@ -114,17 +224,36 @@ func (m *FakeFileSystem) createFile(uncleanedPath string, flag int, perm fs.File
 				Err:  syscall.EISDIR,
 			}
 		}
+		if m.executing[f.path] {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: uncleanedPath,
+				Err:  syscall.ETXTBSY,
+			}
+		}
 		// @todo(perms): are we allowed to open and truncate the file? (check perms)
 		f.bytes = nil
-		f.lastMod = Time()
-		return &FakeFileDescriptor{
+		f.modified()
+		fd := &FakeFileDescriptor{
 			file:   f,
 			cursor: 0,
 			flag:   flag,
-		}, nil
+		}
+		if err := m.decompress(fd, uncleanedPath); err != nil {
+			return nil, err
+		}
+		return fd, nil
+	} else if err != syscall.ENOENT {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
 	}
 
-	if path[len(path)-1] == '/' {
+	// filepath.Clean strips the trailing slash, so we need to check the
+	// original path
+	if strings.HasSuffix(uncleanedPath, "/") {
 		return nil, &os.PathError{
 			Op:   "open",
 			Path: uncleanedPath,
@@ -132,66 +261,377 @@ func (m *FakeFileSystem) createFile(uncleanedPath string, flag int, perm fs.File
 		}
 	}
 
-	parentPath := filepath.Dir(path)
-	if p, ok := m.contents[parentPath]; ok {
-		if !p.isDir {
-			return nil, &os.PathError{
-				Op:   "open",
-				Path: uncleanedPath,
-				Err:  syscall.ENOTDIR,
-			}
+	p, path, err := m.lookupCreate(path)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
 		}
+	}
 
-		// @todo(perms): are we allowed to create the file? (check perms of directory)
-		f := &FakeFile{
-			isDir:   false,
-			path:    path,
-			name:    filepath.Base(path),
-			mode:    perm - umask,
-			lastMod: Time(),
-			parent:  p,
+	// @todo(perms): are we allowed to create the file? (check perms of directory)
+	f := &FakeFile{
+		isDir:  false,
+		path:   path,
+		name:   filepath.Base(path),
+		inode:  m.newInode(perm &^ umask),
+		parent: p,
+	}
+	if m.newFileTemplate != nil {
+		f.bytes = append([]byte(nil), m.newFileTemplate...)
+		if m.isGzipped(f) {
+			f.bytes = gzipBytes(f.bytes)
 		}
-		p.children[path] = f
-		m.contents[path] = f
-		return &FakeFileDescriptor{
-			file:   f,
-			cursor: 0,
-			flag:   flag,
-		}, nil
 	}
+	p.children[path] = f
+	p.modified()
+	m.contents[path] = f
+	fd := &FakeFileDescriptor{
+		file:   f,
+		cursor: 0,
+		flag:   flag,
+	}
+	if err := m.decompress(fd, uncleanedPath); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// lookupParent resolves the parent directory of the (cleaned) path, following
+// symlinks. The first ancestor that is not a directory (or a link to one)
+// results in ENOTDIR, the first one that is missing (or a dangling link) in
+// ENOENT.
+func (m *FakeFileSystem) lookupParent(path string) (*FakeFile, error) {
+	p, err := m.resolve(filepath.Dir(path), true)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isDir {
+		return nil, syscall.ENOTDIR
+	}
+	return p, nil
+}
 
-	return nil, &os.PathError{
-		Op:   "open",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+// lookupCreate resolves where a node created at the (cleaned) path ends up:
+// its parent directory, and its path within that directory, with symlinks in
+// the ancestors resolved.
+// If the last component is a dangling symlink, it's followed, so that the
+// link's target is created (like open(2) with O_CREAT does).
+func (m *FakeFileSystem) lookupCreate(path string) (*FakeFile, string, error) {
+	for links := 0; ; links++ {
+		p, err := m.lookupParent(path)
+		if err != nil {
+			return nil, "", err
+		}
+		newPath := m.childPath(p, filepath.Base(path))
+		l, ok := m.contents[newPath]
+		if !ok || !l.symlink {
+			return p, newPath, nil
+		}
+		if links >= maxSymlinks {
+			return nil, "", syscall.ELOOP
+		}
+		target := l.linkTarget
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(p.path, target)
+		}
+		path = filepath.Clean(target)
 	}
 }
 
 func (m *FakeFileSystem) Create(path string) (File, error) {
-	return m.createFile(path, os.O_RDWR, 0666)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Create", "open", path); err != nil {
+		return nil, err
+	}
+	if err := m.checkWritable("open", path); err != nil {
+		return nil, err
+	}
+	if err := m.checkOpenFiles(path); err != nil {
+		return nil, err
+	}
+	return m.register(m.createFile(path, os.O_RDWR, 0666))
+}
+
+// CreateAll is like Create, but first creates any missing parent directories,
+// similar to MkdirAll.
+// The created directories are given the default directory mode.
+func (m *FakeFileSystem) CreateAll(uncleanedPath string, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("CreateAll", "open", uncleanedPath); err != nil {
+		return nil, err
+	}
+	if err := m.checkWritable("open", uncleanedPath); err != nil {
+		return nil, err
+	}
+	if err := m.checkOpenFiles(uncleanedPath); err != nil {
+		return nil, err
+	}
+	path := m.abs(uncleanedPath)
+	created, err := m.mkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	fd, err := m.createFile(uncleanedPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil && created != nil {
+		// the directories created for the file aren't left behind
+		var nodes []*FakeFile
+		collect(created, &nodes)
+		for _, n := range nodes {
+			m.unlink(n)
+		}
+		delete(created.parent.children, created.path)
+	}
+	return m.register(fd, err)
+}
+
+// checkOpenFiles fails with EMFILE if the limit on open descriptors is reached.
+func (m *FakeFileSystem) checkOpenFiles(path string) error {
+	if m.maxOpenFiles > 0 && m.openFiles >= m.maxOpenFiles {
+		return &os.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  syscall.EMFILE,
+		}
+	}
+	return nil
+}
+
+// register keeps track of the newly opened descriptor f, until it is closed.
+func (m *FakeFileSystem) register(f File, err error) (File, error) {
+	if err != nil {
+		return nil, err
+	}
+	fd := f.(*FakeFileDescriptor)
+	fd.fsys = m
+	fd.file.opens++
+	m.openFiles++
+	return fd, nil
+}
+
+// MkdirAll creates the directory path, along with any missing parents.
+// Newly created directories are given perm (minus umask), existing ones keep
+// their mode. Nothing is done if path already is a directory.
+func (m *FakeFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("MkdirAll", "mkdir", path); err != nil {
+		return err
+	}
+	if m.readOnly {
+		// only fails if a directory would actually have to be created
+		if f, err := m.lookup(path); err != nil || !f.isDir {
+			return erofs("mkdir", path)
+		}
+		return nil
+	}
+	if _, err := m.mkdirAll(m.abs(path), perm); err != nil {
+		return &os.PathError{
+			Op:   "mkdir",
+			Path: path,
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates the (cleaned) directory path, along with any missing
+// parents. Newly created directories are given perm (minus umask).
+// ENOTDIR is returned if any existing component is not a directory.
+// The topmost of the newly created directories is returned, nil if all of
+// them already existed.
+func (m *FakeFileSystem) mkdirAll(path string, perm fs.FileMode) (created *FakeFile, err error) {
+	p := m.root
+	if path == "/" {
+		return nil, nil
+	}
+	parts := strings.Split(path, "/")[1:] // exclude empty ""
+	for i := range parts {
+		pname := filepath.Join(p.path, parts[i])
+		pn, ok := m.contents[pname]
+		if ok && pn.symlink {
+			t, err := m.resolve(pname, true)
+			if err == syscall.ENOENT {
+				return nil, syscall.ENOTDIR // dangling link, can't create a directory there
+			}
+			if err != nil {
+				return nil, err
+			}
+			pn = t
+		}
+		if !ok {
+			// @todo(perms): are we allowed to create the directory? (check perms of parent)
+			pn = &FakeFile{
+				isDir:    true,
+				path:     pname,
+				name:     parts[i],
+				inode:    m.newInode(perm &^ umask),
+				parent:   p,
+				children: map[string]*FakeFile{},
+			}
+			p.children[pname] = pn
+			p.modified()
+			m.contents[pname] = pn
+			if created == nil {
+				created = pn
+			}
+		} else if !pn.isDir {
+			return nil, syscall.ENOTDIR
+		}
+		p = pn
+	}
+	return created, nil
+}
+
+func (m *FakeFileSystem) Open(path string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("open", m.abs(path))
+	if err := m.injectedPathError("Open", "open", path); err != nil {
+		return nil, err
+	}
+	if err := m.checkOpenFiles(path); err != nil {
+		return nil, err
+	}
+	return m.register(m.open(path))
+}
+
+func (m *FakeFileSystem) open(uncleanedPath string) (File, error) {
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	// @todo(perms): are we allowed to open the file (check perms)
+	fd := &FakeFileDescriptor{
+		file:   f,
+		cursor: 0,
+		flag:   os.O_RDONLY,
+	}
+	if err := m.decompress(fd, uncleanedPath); err != nil {
+		return nil, err
+	}
+	return fd, nil
+}
+
+// lookup resolves the node at path, which is made absolute (see abs) first.
+// Since filepath.Clean strips trailing slashes, this is checked on the
+// original path: a trailing slash on anything but a directory is ENOTDIR.
+// Symlinks are followed.
+func (m *FakeFileSystem) lookup(uncleanedPath string) (*FakeFile, error) {
+	f, err := m.resolve(m.abs(uncleanedPath), true)
+	if err != nil {
+		return nil, err
+	}
+	if !f.isDir && strings.HasSuffix(uncleanedPath, "/") {
+		return nil, syscall.ENOTDIR
+	}
+	return f, nil
+}
+
+// maxSymlinks is how many symlinks are followed while resolving a single
+// path, before giving up with ELOOP (same as Linux).
+const maxSymlinks = 40
+
+// resolve finds the node at the (cleaned) path, following symlinks in all of
+// its ancestors. A symlink in the last component is only followed if follow
+// is set.
+func (m *FakeFileSystem) resolve(path string, follow bool) (*FakeFile, error) {
+	if f, ok := m.contents[path]; ok && !f.symlink {
+		return f, nil // fast path: no symlinks involved
+	}
+	links := 0
+	cur := m.root
+	parts := strings.Split(path, "/")[1:] // exclude empty ""
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue // path is "/"
+		}
+		if !cur.isDir {
+			return nil, syscall.ENOTDIR
+		}
+		next, ok := m.contents[filepath.Join(cur.path, parts[i])]
+		if !ok && m.caseInsensitive {
+			// e.g. the target of a symlink, which abs doesn't fold
+			next = cur.childFold(parts[i])
+			ok = next != nil
+		}
+		if !ok {
+			return nil, syscall.ENOENT
+		}
+		if next.symlink && (i < len(parts)-1 || follow) {
+			links++
+			if links > maxSymlinks {
+				return nil, syscall.ELOOP
+			}
+			target := next.linkTarget
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(cur.path, target)
+			}
+			// continue resolving from the link's target
+			rest := parts[i+1:]
+			parts = append(strings.Split(filepath.Clean(target), "/")[1:], rest...)
+			i = -1
+			cur = m.root
+			continue
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+func (m *FakeFileSystem) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record("open", m.abs(path))
+	if err := m.injectedPathError("OpenFile", "open", path); err != nil {
+		return nil, err
+	}
+	if m.readOnly {
+		if canWrite(flag) || (flag&os.O_TRUNC) != 0 {
+			return nil, erofs("open", path)
+		}
+		// O_CREATE only fails if the file would actually have to be created
+		if _, err := m.lookup(path); err != nil && (flag&os.O_CREATE) != 0 {
+			return nil, erofs("open", path)
+		}
+	}
+	if err := m.checkOpenFiles(path); err != nil {
+		return nil, err
+	}
+	return m.register(m.openFile(path, flag&^ignoredFlags, perm))
 }
 
-func (m *FakeFileSystem) Open(uncleanedPath string) (File, error) {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
-		// @todo(perms): are we allowed to open the file (check perms)
+func (m *FakeFileSystem) openFile(uncleanedPath string, flag int, perm os.FileMode) (File, error) {
+	f, err := m.lookup(uncleanedPath)
+	if err == nil && (flag&O_PATH) != 0 {
+		// the descriptor only references the file (or directory), all
+		// other flags are ignored
 		return &FakeFileDescriptor{
 			file:   f,
 			cursor: 0,
-			flag:   os.O_RDONLY,
+			flag:   flag,
 		}, nil
 	}
-	return nil, &os.PathError{
-		Op:   "open",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
-	}
-}
-
-func (m *FakeFileSystem) OpenFile(uncleanedPath string, flag int, perm os.FileMode) (File, error) {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
+	if err == nil {
 		// @todo(perms): are we allowed to open the file? (check perms)
+		if (flag&os.O_CREATE) != 0 && (flag&os.O_EXCL) != 0 {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: uncleanedPath,
+				Err:  syscall.EEXIST,
+			}
+		}
 		if f.isDir {
 			return nil, &os.PathError{
 				Op:   "open",
@@ -199,25 +639,51 @@ func (m *FakeFileSystem) OpenFile(uncleanedPath string, flag int, perm os.FileMo
 				Err:  syscall.EISDIR,
 			}
 		}
-		if (flag&os.O_CREATE) == 1 && (flag&os.O_EXCL) == 1 {
+		if (canWrite(flag) || (flag&os.O_TRUNC) != 0) && m.executing[f.path] {
 			return nil, &os.PathError{
 				Op:   "open",
 				Path: uncleanedPath,
-				Err:  syscall.EEXIST,
+				Err:  syscall.ETXTBSY,
 			}
 		}
-		if (flag & os.O_TRUNC) == 1 {
+		if (flag & os.O_TRUNC) != 0 {
 			f.bytes = nil
+			f.modified()
 		}
-		return &FakeFileDescriptor{
+		fd := &FakeFileDescriptor{
 			file:   f,
 			cursor: 0,
 			flag:   flag,
-		}, nil
+		}
+		if err := m.decompress(fd, uncleanedPath); err != nil {
+			return nil, err
+		}
+		// the cursor must be positioned after the truncation
+		if (flag & os.O_APPEND) != 0 {
+			fd.cursor = int64(len(fd.data()))
+		}
+		return fd, nil
+	}
+	if err != syscall.ENOENT {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
 	}
-	if (flag & os.O_CREATE) == 1 {
+	if (flag&os.O_CREATE) != 0 && (flag&os.O_EXCL) != 0 {
+		// with O_EXCL, a dangling symlink is not followed
+		if _, err := m.resolve(m.abs(uncleanedPath), false); err == nil {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: uncleanedPath,
+				Err:  syscall.EEXIST,
+			}
+		}
+	}
+	if (flag&os.O_CREATE) != 0 && (flag&O_PATH) == 0 { // O_PATH ignores O_CREATE
 		// @todo(perms): are we allowed to create the file? (check perms of directory)
-		if path[len(path)-1] == '/' {
+		if strings.HasSuffix(uncleanedPath, "/") {
 			return nil, &os.PathError{
 				Op:   "open",
 				Path: uncleanedPath,
@@ -233,20 +699,93 @@ func (m *FakeFileSystem) OpenFile(uncleanedPath string, flag int, perm os.FileMo
 	}
 }
 
+func (m *FakeFileSystem) Mkdir(uncleanedPath string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Mkdir", "mkdir", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("mkdir", uncleanedPath); err != nil {
+		return err
+	}
+	return m.mkdir(uncleanedPath, perm)
+}
+
+func (m *FakeFileSystem) mkdir(uncleanedPath string, perm fs.FileMode) error {
+	p, err := m.lookupParent(m.abs(uncleanedPath))
+	if err != nil {
+		return &os.PathError{
+			Op:   "mkdir",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	// a symlink in the last component is not followed
+	path := m.childPath(p, filepath.Base(uncleanedPath))
+	if _, ok := m.contents[path]; ok {
+		return &os.PathError{
+			Op:   "mkdir",
+			Path: uncleanedPath,
+			Err:  syscall.EEXIST,
+		}
+	}
+	// @todo(perms): are we allowed to create the directory? (check perms of parent)
+	d := &FakeFile{
+		isDir:    true,
+		path:     path,
+		name:     filepath.Base(path),
+		inode:    m.newInode(perm &^ umask),
+		parent:   p,
+		children: map[string]*FakeFile{},
+	}
+	p.children[path] = d
+	p.modified()
+	m.contents[path] = d
+	return nil
+}
+
 func (m *FakeFileSystem) Stat(uncleanedPath string) (fs.FileInfo, error) {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
-		return &FakeFileDescriptor{
-			file:   f,
-			cursor: 0,
-			flag:   os.O_RDONLY,
-		}, nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.record("stat", m.abs(uncleanedPath))
+	if err := m.injectedPathError("Stat", "stat", uncleanedPath); err != nil {
+		return nil, err
 	}
-	return nil, &os.PathError{
-		Op:   "stat",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "stat",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	return m.snapshot(f), nil
+}
+
+// Lstat is like Stat, but if path is a symlink, the link itself is described
+// instead of its target.
+func (m *FakeFileSystem) Lstat(uncleanedPath string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.record("lstat", m.abs(uncleanedPath))
+	if err := m.injectedPathError("Lstat", "lstat", uncleanedPath); err != nil {
+		return nil, err
+	}
+	var f *FakeFile
+	var err error
+	if strings.HasSuffix(uncleanedPath, "/") {
+		f, err = m.lookup(uncleanedPath) // trailing slash always resolves
+	} else {
+		f, err = m.resolve(m.abs(uncleanedPath), false)
+	}
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "lstat",
+			Path: uncleanedPath,
+			Err:  err,
+		}
 	}
+	return m.snapshot(f), nil
 }
 
 func readDir(d *FakeFile) []*FakeFile {
@@ -258,31 +797,116 @@ func readDir(d *FakeFile) []*FakeFile {
 	return children
 }
 
-func walkDir(d *FakeFile, fn fs.WalkDirFunc) error {
-	err := fn(d.path, &FakeFileDescriptor{
-		file:   d,
-		cursor: 0,
-		flag:   os.O_RDONLY,
-	}, nil)
-	if err == fs.SkipDir {
-		return nil // successfully skipped directory
+// ReadDir returns the entries of the directory path, sorted by name.
+// Like the entries passed to WalkDir, they're snapshots taken at the time of
+// the call.
+func (m *FakeFileSystem) ReadDir(uncleanedPath string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.record("readdir", m.abs(uncleanedPath))
+	if err := m.injectedPathError("ReadDir", "open", uncleanedPath); err != nil {
+		return nil, err
+	}
+	d, err := m.lookup(uncleanedPath)
+	if err == nil && !d.isDir {
+		err = syscall.ENOTDIR
 	}
 	if err != nil {
-		return err
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
 	}
+	children := readDir(d)
+	entries := make([]fs.DirEntry, len(children))
+	for i, f := range children {
+		entries[i] = m.dirEntry(f)
+	}
+	return entries, nil
+}
 
+// dirEntry returns the entry for f, as seen when reading its directory.
+// Like a real directory listing, it's a point-in-time snapshot: later changes
+// to f (renames, writes, removal) don't affect it.
+func (m *FakeFileSystem) dirEntry(f *FakeFile) *FakeFileDescriptor {
+	return &FakeFileDescriptor{
+		file:     f,
+		cursor:   0,
+		flag:     os.O_RDONLY,
+		info:     m.snapshot(f),
+		path:     f.path,
+		recorder: m,
+	}
+}
+
+// dirEntryNamed is like dirEntry, but reports f under another name, such as
+// that of a symlink pointing to it.
+func (m *FakeFileSystem) dirEntryNamed(f *FakeFile, name string) *FakeFileDescriptor {
+	fd := m.dirEntry(f)
+	fd.info.name = name
+	return fd
+}
+
+// walkDir walks the directory d, which is depth levels below the root of the
+// walk. Directories at maxDepth are reported, but not descended into.
+// A negative maxDepth means there is no limit.
+// The entries of a directory are read before visiting them, entries that are
+// removed by fn in the meantime are skipped.
+func (m *FakeFileSystem) walkDir(d *FakeFile, path string, depth, maxDepth int, fn fs.WalkDirFunc) error {
+	// fn is called without holding the lock, so that it may use the file
+	// system itself
+	m.mu.RLock()
+	entry := m.dirEntry(d)
+	m.mu.RUnlock()
+	err := fn(path, entry, nil)
+	if err == fs.SkipDir {
+		return nil // successfully skipped directory
+	}
+	if err != nil {
+		return err
+	}
+	if !d.isDir {
+		return nil // only the root may be a file, it's not opened
+	}
+	if maxDepth >= 0 && depth >= maxDepth {
+		return nil
+	}
+
+	m.mu.RLock()
+	denied := !m.canAccess(d, permRead)
+	m.record("readdir", d.path)
 	dirEntries := readDir(d)
+	m.mu.RUnlock()
+	if denied {
+		// like filepath.WalkDir, fn is called a second time for a directory
+		// that can't be read
+		err = fn(path, entry, &os.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  syscall.EACCES,
+		})
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
 	for _, d := range dirEntries {
-		if d.isDir {
+		m.mu.RLock()
+		removed := m.contents[d.path] != d
+		isDir, entry := d.isDir, m.dirEntry(d)
+		m.mu.RUnlock()
+		if removed {
+			continue // removed during the walk
+		}
+		// like filepath.WalkDir, paths are relative to the root as given
+		childPath := filepath.Join(path, d.name)
+		if isDir {
 			// we descend into directories first, before we continue on in the
 			// current directory
-			err = walkDir(d, fn)
+			err = m.walkDir(d, childPath, depth+1, maxDepth, fn)
 		} else {
-			err = fn(d.path, &FakeFileDescriptor{
-				file:   d,
-				cursor: 0,
-				flag:   os.O_RDONLY,
-			}, nil)
+			err = fn(childPath, entry, nil)
 		}
 		if err == fs.SkipDir {
 			return nil // successfully skipped rest of directory
@@ -294,29 +918,173 @@ func walkDir(d *FakeFile, fn fs.WalkDirFunc) error {
 	return nil
 }
 
-func (m *FakeFileSystem) WalkDir(uncleanedRoot string, fn fs.WalkDirFunc) (err error) {
-	// @fixme: the path passed to fn should always have root as prefix
-	root := filepath.Clean(uncleanedRoot)
-	r, ok := m.contents[root]
+func (m *FakeFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return m.walk(root, -1, fn)
+}
 
-	// @todo(perms): maybe we aren't allowed to open some dirs/files?
+// WalkDirDepth is like WalkDir, but descends at most maxDepth levels below
+// root: 0 visits only root, 1 visits root and its direct children, and so on.
+// Directories beyond the limit are reported, but not descended into.
+func (m *FakeFileSystem) WalkDirDepth(root string, maxDepth int, fn fs.WalkDirFunc) error {
+	return m.walk(root, maxDepth, fn)
+}
+
+// WalkDirRel is like WalkDir, but passes fn paths relative to root, as
+// fs.WalkDir would on an fs.Sub of root.
+// The root itself is reported as ".".
+func (m *FakeFileSystem) WalkDirRel(root string, fn func(rel string, d fs.DirEntry, err error) error) error {
+	root = filepath.Clean(root)
+	return m.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return relErr
+		}
+		return fn(rel, d, err)
+	})
+}
 
-	if !ok {
+// WalkDirSkipHidden is like WalkDir, but skips hidden entries, i.e. those
+// whose name starts with a ".": fn isn't called for them, and hidden
+// directories aren't descended into.
+// The root itself is always visited, even if it is hidden.
+func (m *FakeFileSystem) WalkDirSkipHidden(root string, fn fs.WalkDirFunc) error {
+	root = filepath.Clean(root)
+	return m.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path != root && strings.HasPrefix(filepath.Base(path), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(path, d, err)
+	})
+}
+
+// WalkDirFollow is like WalkDir, but follows symlinks (like find -L): links to
+// directories are descended into, links to files are reported with the
+// type of their target. Dangling links are reported as links.
+// A link to one of the directories currently being walked would make the walk
+// loop, so instead fn is called for it with an ELOOP error.
+func (m *FakeFileSystem) WalkDirFollow(uncleanedRoot string, fn fs.WalkDirFunc) (err error) {
+	m.mu.RLock()
+	root := m.abs(uncleanedRoot)
+	r, err := m.resolve(root, true)
+	var entry *FakeFileDescriptor
+	if err == nil {
+		entry = m.dirEntryNamed(r, filepath.Base(root))
+	}
+	m.mu.RUnlock()
+
+	if err != nil {
+		err = fn(root, nil, &os.PathError{
+			Op:   "stat",
+			Path: uncleanedRoot,
+			Err:  err,
+		})
+	} else {
+		err = m.walkDirFollow(root, r, entry, map[*FakeFile]bool{}, fn)
+	}
+
+	if err == fs.SkipAll || err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkDirFollow walks d, which is reached through path, following symlinks.
+// The directories currently being walked are tracked in ancestors.
+func (m *FakeFileSystem) walkDirFollow(path string, d *FakeFile, entry *FakeFileDescriptor, ancestors map[*FakeFile]bool, fn fs.WalkDirFunc) error {
+	err := fn(path, entry, nil)
+	if err == fs.SkipDir {
+		return nil // successfully skipped directory
+	}
+	if err != nil || !d.isDir {
+		return err
+	}
+	ancestors[d] = true
+	defer delete(ancestors, d)
+
+	m.mu.RLock()
+	m.record("readdir", d.path)
+	dirEntries := readDir(d)
+	m.mu.RUnlock()
+	for _, c := range dirEntries {
+		m.mu.RLock()
+		removed := m.contents[c.path] != c
+		childPath := filepath.Join(path, c.name)
+		t, rerr := c, error(nil)
+		if c.symlink {
+			t, rerr = m.resolve(c.path, true)
+		}
+		var entry *FakeFileDescriptor
+		if rerr == nil {
+			entry = m.dirEntryNamed(t, c.name)
+		} else {
+			entry = m.dirEntry(c) // dangling link
+		}
+		m.mu.RUnlock()
+		if removed {
+			continue // removed during the walk
+		}
+		switch {
+		case rerr == nil && t.isDir && ancestors[t]:
+			err = fn(childPath, entry, &os.PathError{
+				Op:   "open",
+				Path: childPath,
+				Err:  syscall.ELOOP,
+			})
+			if err == fs.SkipDir {
+				continue // only skips the link
+			}
+		case rerr == nil && t.isDir:
+			err = m.walkDirFollow(childPath, t, entry, ancestors, fn)
+		default:
+			err = fn(childPath, entry, nil)
+		}
+		if err == fs.SkipDir {
+			return nil // successfully skipped rest of directory
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *FakeFileSystem) walk(uncleanedRoot string, maxDepth int, fn fs.WalkDirFunc) (err error) {
+	// like filepath.WalkDir, the paths passed to fn are the root as given
+	// (but cleaned), joined with the path relative to it
+	display := filepath.Clean(uncleanedRoot)
+	m.mu.RLock()
+	root := m.abs(uncleanedRoot)
+	m.record("lstat", root)
+	// symlinks in the ancestors are followed, but not the root itself
+	r, err := m.resolve(root, false)
+	denied := err == nil && r.isDir && !m.canAccess(r, permRead)
+	m.mu.RUnlock()
+
+	if err != nil {
 		err = &os.PathError{
 			Op:   "lstat",
 			Path: uncleanedRoot,
-			Err:  syscall.ENOENT,
+			Err:  err,
+		}
+	} else if denied {
+		err = &os.PathError{
+			Op:   "open",
+			Path: uncleanedRoot,
+			Err:  syscall.EACCES,
 		}
 	}
 
 	if err != nil {
-		err = fn(root, &FakeFileDescriptor{
+		err = fn(display, &FakeFileDescriptor{
 			file:   r,
 			cursor: 0,
 			flag:   os.O_RDONLY,
 		}, err)
 	} else {
-		err = walkDir(r, fn)
+		err = m.walkDir(r, display, 0, maxDepth, fn)
 	}
 
 	if err == fs.SkipAll || err == fs.SkipDir {
@@ -326,48 +1094,151 @@ func (m *FakeFileSystem) WalkDir(uncleanedRoot string, fn fs.WalkDirFunc) (err e
 }
 
 func (m *FakeFileSystem) Truncate(uncleanedPath string, size int64) error {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
-		if f.isDir {
-			return &os.PathError{
-				Op:   "truncate",
-				Path: uncleanedPath,
-				Err:  syscall.EISDIR,
-			}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Truncate", "truncate", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("truncate", uncleanedPath); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  syscall.EINVAL,
+		}
+	}
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	if f.isDir {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  syscall.EISDIR,
 		}
-		// @todo(perm): check permissions
+	}
+	if m.busy[f.path] {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  syscall.EBUSY,
+		}
+	}
+	if m.executing[f.path] {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  syscall.ETXTBSY,
+		}
+	}
+	// @todo(perm): check permissions
+	if err := m.checkSpace(size - int64(len(f.bytes))); err != nil {
+		return &os.PathError{
+			Op:   "truncate",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	if size <= int64(len(f.bytes)) {
 		f.bytes = f.bytes[:size]
-		return nil
+	} else {
+		// the file is extended with zero bytes
+		f.bytes = append(f.bytes, make([]byte, size-int64(len(f.bytes)))...)
 	}
-	return &os.PathError{
-		Op:   "truncate",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+	f.modified()
+	return nil
+}
+
+// ReadFile returns a copy of the file's contents, so the caller may freely
+// modify the returned slice.
+func (m *FakeFileSystem) ReadFile(path string) ([]byte, error) {
+	m.mu.Lock() // the access time (and possibly the cache) is updated
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("ReadFile", "open", path); err != nil {
+		return nil, err
+	}
+	if m.readCache != nil {
+		return m.readFileCached(path)
+	}
+	bs, err := m.readFileNoCopy(path)
+	if err != nil {
+		return nil, err
 	}
+	return append([]byte(nil), bs...), nil
 }
 
-func (m *FakeFileSystem) ReadFile(uncleanedPath string) ([]byte, error) {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
-		if f.isDir {
+// ReadFileNoCopy is like ReadFile, but avoids the defensive copy by returning
+// the file's underlying byte slice.
+// The returned slice aliases the file's contents: it MUST NOT be modified, and
+// it may change on subsequent writes to the file.
+// If the contents have to be decoded first (see WithTransparentGzip and
+// WithTextMode), a freshly decoded buffer is returned instead, which does not
+// alias the file.
+// Meant as an escape hatch for hot paths, such as benchmarks.
+func (m *FakeFileSystem) ReadFileNoCopy(path string) ([]byte, error) {
+	m.mu.Lock() // the access time is updated
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("ReadFileNoCopy", "open", path); err != nil {
+		return nil, err
+	}
+	return m.readFileNoCopy(path)
+}
+
+func (m *FakeFileSystem) readFileNoCopy(uncleanedPath string) ([]byte, error) {
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	if f.isDir {
+		return nil, &os.PathError{
+			Op:   "read",
+			Path: uncleanedPath,
+			Err:  syscall.EISDIR,
+		}
+	}
+	m.accessed(f)
+	bs := f.bytes
+	if m.isGzipped(f) {
+		bs, err = gunzip(bs)
+		if err != nil {
 			return nil, &os.PathError{
 				Op:   "read",
 				Path: uncleanedPath,
-				Err:  syscall.EISDIR,
+				Err:  err,
 			}
 		}
-		return f.bytes, nil
 	}
-	return nil, &os.PathError{
-		Op:   "open",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+	if m.textMode {
+		bs = fromText(bs)
 	}
+	return bs, nil
 }
 
 func (m *FakeFileSystem) WriteFile(uncleanedPath string, data []byte, perm os.FileMode) error {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("WriteFile", "open", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("open", uncleanedPath); err != nil {
+		return err
+	}
+	path := m.abs(uncleanedPath)
+	if m.textMode {
+		data = toText(data, m.lineEnding)
+	}
+	if f, err := m.lookup(uncleanedPath); err == nil {
 		if f.isDir {
 			return &os.PathError{
 				Op:   "open",
@@ -375,42 +1246,86 @@ func (m *FakeFileSystem) WriteFile(uncleanedPath string, data []byte, perm os.Fi
 				Err:  syscall.EISDIR,
 			}
 		}
-		f.bytes = data
-		return nil
-	}
-	parentPath := filepath.Dir(path)
-	if p, ok := m.contents[parentPath]; ok {
-		// @todo(perms): check folder perms
-		if !p.isDir {
+		if m.executing[f.path] {
 			return &os.PathError{
 				Op:   "open",
 				Path: uncleanedPath,
-				Err:  syscall.ENOTDIR,
+				Err:  syscall.ETXTBSY,
 			}
 		}
-		f := &FakeFile{
-			isDir:   false,
-			path:    path,
-			name:    filepath.Base(path),
-			bytes:   data,
-			mode:    perm - umask,
-			lastMod: Time(),
-			parent:  p,
+		if m.isGzipped(f) {
+			data = gzipBytes(data)
 		}
-		p.children[path] = f
-		m.contents[path] = f
+		// if the data doesn't fit, the old content is kept
+		if err := m.checkSpace(int64(len(data) - len(f.bytes))); err != nil {
+			return &os.PathError{
+				Op:   "write",
+				Path: uncleanedPath,
+				Err:  err,
+			}
+		}
+		f.bytes = data
+		f.modified()
+		m.syncWriteFile(f)
 		return nil
+	} else if err != syscall.ENOENT {
+		return &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
 	}
-	return &os.PathError{
-		Op:   "open",
-		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+	p, path, err := m.lookupCreate(path)
+	if err != nil {
+		return &os.PathError{
+			Op:   "open",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	// @todo(perms): check folder perms
+	f := &FakeFile{
+		isDir:  false,
+		path:   path,
+		name:   filepath.Base(path),
+		inode:  m.newInode(perm &^ umask),
+		parent: p,
 	}
+	if m.isGzipped(f) {
+		data = gzipBytes(data)
+	}
+	// if the data doesn't fit, the file isn't created at all
+	if err := m.checkSpace(int64(len(data))); err != nil {
+		return &os.PathError{
+			Op:   "write",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	f.bytes = data
+	p.children[path] = f
+	p.modified()
+	m.contents[path] = f
+	m.syncWriteFile(f)
+	return nil
 }
 
 func (m *FakeFileSystem) Remove(uncleanedPath string) error {
-	path := filepath.Clean(uncleanedPath)
-	if f, ok := m.contents[path]; ok {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Remove", "remove", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("remove", uncleanedPath); err != nil {
+		return err
+	}
+	// symlinks are not resolved, Remove (and RemoveAll) operate on the link
+	// itself, never on its target
+	// locks and xattrs live on the node, so they are dropped together with
+	// it, and don't leak to a file later created at the same path
+	f, err := m.resolve(m.abs(uncleanedPath), false)
+	if err == nil {
+		path := f.path
 		if f == m.root {
 			return &os.PathError{
 				Op:   "remove",
@@ -418,6 +1333,13 @@ func (m *FakeFileSystem) Remove(uncleanedPath string) error {
 				Err:  syscall.EPERM,
 			}
 		}
+		if m.busy[path] {
+			return &os.PathError{
+				Op:   "remove",
+				Path: uncleanedPath,
+				Err:  syscall.EBUSY,
+			}
+		}
 		if f.isDir && len(f.children) > 0 {
 			return &os.PathError{
 				Op:   "remove",
@@ -426,49 +1348,563 @@ func (m *FakeFileSystem) Remove(uncleanedPath string) error {
 			}
 		}
 		// @todo(perms): check permissions
-		delete(m.contents, path)
+		m.unlink(f)
 		delete(f.parent.children, path) // @todo: write tests to verify that no such references are forgotten about!!!
+		f.parent.modified()
 		return nil
 	}
+	// the path may be missing because an ancestor isn't a directory (ENOTDIR)
 	return &os.PathError{
 		Op:   "remove",
 		Path: uncleanedPath,
-		Err:  syscall.ENOENT,
+		Err:  err,
+	}
+}
+
+func (m *FakeFileSystem) RemoveAll(uncleanedPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("RemoveAll", "remove", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("remove", uncleanedPath); err != nil {
+		return err
+	}
+	f, err := m.resolve(m.abs(uncleanedPath), false)
+	if err == syscall.ENOENT {
+		// like os.RemoveAll, there's nothing to do
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{
+			Op:   "lstat",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	path := f.path
+	if f == m.root {
+		return &os.PathError{
+			Op:   "remove",
+			Path: uncleanedPath,
+			Err:  syscall.EPERM,
+		}
+	}
+	// @todo(perms): check perms
+	// the nodes are collected first, so that the tree isn't modified while
+	// it is traversed
+	var nodes []*FakeFile
+	collect(f, &nodes)
+	for _, n := range nodes {
+		m.unlink(n)
+	}
+	delete(f.parent.children, path)
+	f.parent.modified()
+	return nil
+}
+
+// unlink removes f from the contents.
+// The file is gone once its last name is removed, but if descriptors are
+// still open on it, it lives on (taking up space) as an orphan, until the
+// last of them is closed.
+func (m *FakeFileSystem) unlink(f *FakeFile) {
+	delete(m.contents, f.path)
+	f.nlink--
+	if f.nlink == 0 && f.opens > 0 {
+		if m.orphans == nil {
+			m.orphans = map[*inode]bool{}
+		}
+		m.orphans[f.inode] = true
+	}
+}
+
+// collect appends f and all of its descendants to nodes.
+func collect(f *FakeFile, nodes *[]*FakeFile) {
+	*nodes = append(*nodes, f)
+	for _, c := range f.children {
+		collect(c, nodes)
+	}
+}
+
+// Link creates newname as a hard link to the file oldname: both names refer
+// to the same file, sharing its contents and metadata, until one of them is
+// removed. The file itself is only gone once its last name is removed.
+// Like link(2), a symlink oldname is not followed, the new name refers to
+// the link itself, and directories can't be linked (EPERM).
+func (m *FakeFileSystem) Link(uncleanedOld, uncleanedNew string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	linkErr := func(err error) error {
+		return &os.LinkError{
+			Op:  "link",
+			Old: uncleanedOld,
+			New: uncleanedNew,
+			Err: err,
+		}
+	}
+	if err := m.injected("Link", uncleanedOld); err != nil {
+		return linkErr(err)
+	}
+	if m.readOnly {
+		return linkErr(syscall.EROFS)
+	}
+	f, err := m.resolve(m.abs(uncleanedOld), false)
+	if err != nil {
+		return linkErr(err)
+	}
+	if f.isDir {
+		return linkErr(syscall.EPERM)
+	}
+	p, err := m.lookupParent(m.abs(uncleanedNew))
+	if err != nil {
+		return linkErr(err)
+	}
+	path := m.childPath(p, filepath.Base(m.abs(uncleanedNew)))
+	if _, ok := m.contents[path]; ok {
+		return linkErr(syscall.EEXIST)
+	}
+	// @todo(perms): are we allowed to create the link? (check perms of directory)
+	l := &FakeFile{
+		inode:      f.inode,
+		path:       path,
+		name:       filepath.Base(path),
+		symlink:    f.symlink,
+		linkTarget: f.linkTarget,
+		parent:     p,
+	}
+	f.nlink++
+	f.changed()
+	p.children[path] = l
+	p.modified()
+	m.contents[path] = l
+	return nil
+}
+
+func (m *FakeFileSystem) Rename(uncleanedOld, uncleanedNew string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injected("Rename", uncleanedOld); err != nil {
+		return &os.LinkError{
+			Op:  "rename",
+			Old: uncleanedOld,
+			New: uncleanedNew,
+			Err: err,
+		}
+	}
+	if m.readOnly {
+		return &os.LinkError{
+			Op:  "rename",
+			Old: uncleanedOld,
+			New: uncleanedNew,
+			Err: syscall.EROFS,
+		}
+	}
+	oldPath := m.abs(uncleanedOld)
+	newPath := m.abs(uncleanedNew)
+	linkErr := func(err error) error {
+		return &os.LinkError{
+			Op:  "rename",
+			Old: uncleanedOld,
+			New: uncleanedNew,
+			Err: err,
+		}
+	}
+
+	// symlinks in the last component are not followed, the link itself is
+	// renamed (or replaced)
+	f, err := m.resolve(oldPath, false)
+	if err != nil {
+		return linkErr(err)
+	}
+	oldPath = f.path
+	p, err := m.lookupParent(newPath)
+	if err != nil {
+		return linkErr(err)
+	}
+	newPath = m.childPath(p, filepath.Base(newPath))
+	if f == m.root || m.busy[oldPath] || m.busy[newPath] {
+		return linkErr(syscall.EBUSY)
+	}
+	if oldPath == newPath {
+		// on a case-insensitive file system, a case-only rename (/Foo ->
+		// /foo) resolves to the same file, only its name changes
+		if name := filepath.Base(filepath.Clean(uncleanedNew)); m.caseInsensitive && name != f.name {
+			delete(p.children, oldPath)
+			newPath = filepath.Join(p.path, name)
+			m.move(f, newPath)
+			f.name = name
+			f.changed()
+			p.children[newPath] = f
+			p.modified()
+		}
+		return nil
+	}
+	if f.isDir && strings.HasPrefix(newPath, oldPath+"/") {
+		// can't move a directory into itself
+		return linkErr(syscall.EINVAL)
+	}
+	// @todo(perms): check perms of both parent directories
+	if t, ok := m.contents[newPath]; ok {
+		switch {
+		case t.inode == f.inode:
+			// hard links of the same file, like rename(2), do nothing
+			return nil
+		case f.isDir && !t.isDir:
+			return linkErr(syscall.ENOTDIR)
+		case !f.isDir && t.isDir:
+			return linkErr(syscall.EISDIR)
+		case t.isDir && len(t.children) > 0:
+			return linkErr(syscall.ENOTEMPTY)
+		}
+		// the destination is replaced
+		m.unlink(t)
+		delete(t.parent.children, newPath)
+	}
+
+	delete(f.parent.children, oldPath)
+	f.parent.modified()
+	m.move(f, newPath)
+	f.name = filepath.Base(newPath)
+	f.parent = p
+	f.changed()
+	p.children[newPath] = f
+	p.modified()
+	return nil
+}
+
+// move re-keys f, and all of its descendants, from their current path to
+// newPath.
+func (m *FakeFileSystem) move(f *FakeFile, newPath string) {
+	delete(m.contents, f.path)
+	f.path = newPath
+	m.contents[newPath] = f
+	if f.isDir {
+		children := maps.Values(f.children)
+		f.children = make(map[string]*FakeFile, len(children))
+		for _, c := range children {
+			childPath := filepath.Join(newPath, filepath.Base(c.path))
+			m.move(c, childPath)
+			f.children[childPath] = c
+		}
+	}
+}
+
+func (m *FakeFileSystem) Symlink(oldname, uncleanedNew string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injected("Symlink", uncleanedNew); err != nil {
+		return &os.LinkError{
+			Op:  "symlink",
+			Old: oldname,
+			New: uncleanedNew,
+			Err: err,
+		}
+	}
+	if m.readOnly {
+		return &os.LinkError{
+			Op:  "symlink",
+			Old: oldname,
+			New: uncleanedNew,
+			Err: syscall.EROFS,
+		}
+	}
+	path := m.abs(uncleanedNew)
+	linkErr := func(err error) error {
+		return &os.LinkError{
+			Op:  "symlink",
+			Old: oldname,
+			New: uncleanedNew,
+			Err: err,
+		}
+	}
+	p, err := m.lookupParent(path)
+	if err != nil {
+		return linkErr(err)
+	}
+	path = m.childPath(p, filepath.Base(path))
+	if _, ok := m.contents[path]; ok {
+		return linkErr(syscall.EEXIST)
+	}
+	// @todo(perms): are we allowed to create the link? (check perms of directory)
+	l := &FakeFile{
+		isDir:      false,
+		path:       path,
+		name:       filepath.Base(path),
+		inode:      m.newInode(fs.ModeSymlink | 0777),
+		symlink:    true,
+		linkTarget: oldname,
+		parent:     p,
+	}
+	p.children[path] = l
+	p.modified()
+	m.contents[path] = l
+	return nil
+}
+
+func (m *FakeFileSystem) Readlink(uncleanedPath string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.injectedPathError("Readlink", "readlink", uncleanedPath); err != nil {
+		return "", err
+	}
+	l, err := m.resolve(m.abs(uncleanedPath), false)
+	if err == nil && !l.symlink {
+		err = syscall.EINVAL
+	}
+	if err != nil {
+		return "", &os.PathError{
+			Op:   "readlink",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	return l.linkTarget, nil
+}
+
+// chmodBits are the bits of a mode that Chmod changes, like os.Chmod.
+const chmodBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Chmod changes the mode of the file to mode, keeping its type.
+// If the file is a symlink, the mode of its target is changed.
+// Like chmod(2), this changes the file's ctime, but not its mtime.
+func (m *FakeFileSystem) Chmod(uncleanedPath string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Chmod", "chmod", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("chmod", uncleanedPath); err != nil {
+		return err
+	}
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return &os.PathError{
+			Op:   "chmod",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	// @todo(perms): only the owner may change the mode
+	f.mode = f.mode&^chmodBits | mode&chmodBits
+	f.changed()
+	return nil
+}
+
+// Chtimes changes the access and modification times of the file, like
+// os.Chtimes. A zero time.Time leaves the respective time unchanged.
+// If the file is a symlink, the times of its target are changed.
+func (m *FakeFileSystem) Chtimes(uncleanedPath string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Chtimes", "chtimes", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("chtimes", uncleanedPath); err != nil {
+		return err
+	}
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return &os.PathError{
+			Op:   "chtimes",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	if !atime.IsZero() {
+		f.lastAccess = atime
+	}
+	if !mtime.IsZero() {
+		f.lastMod = mtime
+	}
+	f.changed()
+	return nil
+}
+
+// abs cleans path, after joining it to the working directory if it's
+// relative. On a case-insensitive file system, the names of existing files
+// are spelled the way they are stored, see WithCaseInsensitive.
+func (m *FakeFileSystem) abs(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.cwd, path)
+	}
+	path = filepath.Clean(path)
+	if m.caseInsensitive {
+		path = m.fold(path)
 	}
+	return path
 }
 
-func (m *FakeFileSystem) RemoveAll(path string) error {
-	return m.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		fd := d.(*FakeFileDescriptor)
-		if fd.file == m.root {
-			return &os.PathError{
-				Op:   "remove",
-				Path: path,
-				Err:  syscall.EPERM,
-			}
+// Chdir changes the working directory, against which relative paths are
+// resolved, to dir. Symlinks in dir are resolved.
+func (m *FakeFileSystem) Chdir(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, err := m.lookup(dir)
+	if err == nil && !d.isDir {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		return &os.PathError{
+			Op:   "chdir",
+			Path: dir,
+			Err:  err,
 		}
-		// @todo(perms): check perms
-		delete(m.contents, path)
-		// technically only needed for the top-most directory, for all others
-		// the parent itself was already deleted, no need to remove the
-		// children reference
-		delete(fd.file.parent.children, path)
-		return nil
-	})
+	}
+	m.cwd = d.path
+	return nil
+}
+
+// Getwd returns the working directory, which is / unless changed by Chdir.
+func (m *FakeFileSystem) Getwd() (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cwd, nil
 }
 
+// SetBusy marks the path as busy (in use), causing Remove, Rename, and
+// Truncate on it to fail with EBUSY until it is cleared again.
+func (m *FakeFileSystem) SetBusy(path string, busy bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = m.abs(path)
+	if !busy {
+		delete(m.busy, path)
+		return
+	}
+	if m.busy == nil {
+		m.busy = map[string]bool{}
+	}
+	m.busy[path] = true
+}
+
+// SetExecuting marks the path as being executed, causing opening it for
+// writing (including Create), Truncate, and WriteFile to fail with ETXTBSY
+// ("text file busy") until it is cleared again.
+func (m *FakeFileSystem) SetExecuting(path string, executing bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = m.abs(path)
+	if !executing {
+		delete(m.executing, path)
+		return
+	}
+	if m.executing == nil {
+		m.executing = map[string]bool{}
+	}
+	m.executing[path] = true
+}
+
+// FakeFile is a name in the tree. Hard links (see Link) are several names
+// sharing the same inode.
 type FakeFile struct {
+	*inode
 	isDir      bool
 	path, name string
+
+	symlink    bool
+	linkTarget string // symlink = true only
+
+	parent   *FakeFile
+	children map[string]*FakeFile // isDir = true only
+}
+
+// inode holds the contents and metadata of a file, shared by all of its
+// names.
+type inode struct {
 	bytes      []byte
 	mode       fs.FileMode
 	lastMod    time.Time
+	lastChange time.Time                   // ctime, changes on content and metadata changes
+	lastAccess time.Time                   // atime, see WithNoAtime
+	durable    []byte                      // contents as of the last sync, see WithCrashSimulation
+	gen        uint64                      // incremented on every change to the contents
+	opens      int                         // number of descriptors open on the file
+	nlink      int                         // number of names of the file, see Link
+	xattrs     map[string][]byte           // see Setxattr
+	locks      map[*FakeFileDescriptor]int // see Flock
+	uid, gid   int                         // owner, see WithUser
+}
 
-	parent   *FakeFile
-	children map[string]*FakeFile // isDir = true only
+// newInode returns the inode of a new file with a single name, owned by
+// root.
+func newInode(mode fs.FileMode) *inode {
+	return &inode{
+		mode:       mode,
+		lastMod:    Time(),
+		lastChange: Time(),
+		lastAccess: Time(),
+		nlink:      1,
+	}
+}
+
+// newInode returns the inode of a new file with a single name, owned by the
+// acting user.
+func (m *FakeFileSystem) newInode(mode fs.FileMode) *inode {
+	i := newInode(mode)
+	i.uid, i.gid = m.uid, m.gid
+	return i
+}
+
+// snapshot captures the current metadata of f.
+func (f *FakeFile) snapshot() *fakeFileInfo {
+	return &fakeFileInfo{
+		name:    f.name,
+		size:    f.size(),
+		mode:    f.fileMode(),
+		modTime: f.lastMod,
+		isDir:   f.isDir,
+		sys:     f.stat(),
+	}
+}
+
+// size reports the size of f, for a symlink that's the length of its target
+// path (as reported by lstat(2)).
+func (f *FakeFile) size() int64 {
+	if f.symlink {
+		return int64(len(f.linkTarget))
+	}
+	return int64(len(f.bytes))
+}
+
+// modified records a change to the contents of f.
+func (f *FakeFile) modified() {
+	f.gen++
+	f.lastMod = Time()
+	f.lastChange = f.lastMod
+}
+
+// accessed records a read of the contents of f.
+func (f *FakeFile) accessed() {
+	f.lastAccess = Time()
+}
+
+// accessed records a read of f, unless mounted with WithNoAtime (or
+// read-only, which can't update access times either).
+func (m *FakeFileSystem) accessed(f *FakeFile) {
+	if !m.noAtime && !m.readOnly {
+		f.accessed()
+	}
+}
+
+// changed records a change to the metadata of f.
+func (f *FakeFile) changed() {
+	f.lastChange = Time()
+}
+
+// fileMode reports the mode of f, carrying exactly one type bit (or none, for
+// regular files). Nodes whose type is not a recognized category are reported
+// as fs.ModeIrregular.
+func (f *FakeFile) fileMode() fs.FileMode {
+	bits := f.mode &^ fs.ModeType
+	if f.isDir {
+		return bits | fs.ModeDir
+	}
+	switch t := f.mode.Type(); t {
+	case 0, fs.ModeSymlink, fs.ModeNamedPipe, fs.ModeSocket, fs.ModeDevice, fs.ModeDevice | fs.ModeCharDevice, fs.ModeIrregular:
+		return bits | t
+	default:
+		return bits | fs.ModeIrregular
+	}
 }
 
 type FakeFileDescriptor struct {
@@ -476,25 +1912,189 @@ type FakeFileDescriptor struct {
 	cursor int64
 	flag   int
 	closed bool
+
+	info         *fakeFileInfo   // read-time snapshot, set on directory entries
+	gzipped      bool            // the file is compressed, see WithTransparentGzip
+	decompressed []byte          // gzipped only: plaintext of the file
+	dirty        bool            // gzipped only: decompressed was written to
+	synced       bool            // Sync was called, see Synced
+	dirEntries   []fs.DirEntry   // directories only: listing read by ReadDir
+	fd           uintptr         // assigned on first call to Fd
+	fsys         *FakeFileSystem // set while opened through the file system
+	path         string          // directory entries only: path at the time of the read
+	recorder     *FakeFileSystem // directory entries only: records Info, see Record
 }
 
+// fakeFileInfo is a snapshot of a file's metadata taken at the time of the
+// directory read.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	isDir   bool
+	sys     any
+}
+
+var _ fs.FileInfo = (*fakeFileInfo)(nil)
+
+func (i *fakeFileInfo) Name() string       { return i.name }
+func (i *fakeFileInfo) Size() int64        { return i.size }
+func (i *fakeFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *fakeFileInfo) ModTime() time.Time { return i.modTime }
+func (i *fakeFileInfo) IsDir() bool        { return i.isDir }
+func (i *fakeFileInfo) Sys() any           { return i.sys }
+
 var _ File = (*FakeFileDescriptor)(nil)
+var _ Fder = (*FakeFileDescriptor)(nil)
+var _ io.ReaderAt = (*FakeFileDescriptor)(nil)
+var _ io.WriterAt = (*FakeFileDescriptor)(nil)
+var _ io.WriterTo = (*FakeFileDescriptor)(nil)
+var _ io.ReaderFrom = (*FakeFileDescriptor)(nil)
 var _ fs.DirEntry = (*FakeFileDescriptor)(nil)
 var _ fs.FileInfo = (*FakeFileDescriptor)(nil)
+var _ fs.ReadDirFile = (*FakeFileDescriptor)(nil)
+
+// accessMode masks the access mode bits (O_RDONLY, O_WRONLY, O_RDWR) of an
+// open flag.
+const accessMode = os.O_RDONLY | os.O_WRONLY | os.O_RDWR
+
+// O_PATH opens a descriptor that only references a file or directory, like
+// Linux's O_PATH (which the syscall package doesn't define).
+// Such a descriptor can be used for Stat and Fd, but Read and Write fail
+// with EBADF.
+const O_PATH = 0x200000
+
+// ignoredFlags are accepted by OpenFile, but don't change its behavior:
+// there's no exec to close descriptors on, and regular files never block.
+const ignoredFlags = syscall.O_CLOEXEC | syscall.O_NONBLOCK
+
+// canRead tells whether a descriptor opened with flag may be read from.
+func canRead(flag int) bool {
+	if (flag & O_PATH) != 0 {
+		return false
+	}
+	mode := flag & accessMode
+	return mode == os.O_RDONLY || mode == os.O_RDWR
+}
+
+// canWrite tells whether a descriptor opened with flag may be written to.
+func canWrite(flag int) bool {
+	if (flag & O_PATH) != 0 {
+		return false
+	}
+	mode := flag & accessMode
+	return mode == os.O_WRONLY || mode == os.O_RDWR
+}
+
+// data returns the contents as seen through this descriptor.
+func (m *FakeFileDescriptor) data() []byte {
+	if m.gzipped {
+		return m.decompressed
+	}
+	return m.file.bytes
+}
+
+// setData replaces the contents as seen through this descriptor.
+func (m *FakeFileDescriptor) setData(bs []byte) {
+	if m.gzipped {
+		m.decompressed = bs
+		m.dirty = true
+		return
+	}
+	m.file.bytes = bs
+}
+
+// fdCount counts the fake file descriptor numbers handed out so far.
+var fdCount atomic.Uintptr
+
+// Fd returns a synthetic file descriptor number, which is unique to this
+// descriptor and stable across calls.
+// It is NOT a real OS handle and must not be passed to syscalls.
+func (m *FakeFileDescriptor) Fd() uintptr {
+	m.lock()
+	defer m.unlock()
+	if m.fd == 0 {
+		m.fd = fdCount.Add(1) + 2 // after stdin, stdout, and stderr
+	}
+	return m.fd
+}
+
+// lock locks the file system the descriptor was opened through, if any.
+func (m *FakeFileDescriptor) lock() {
+	if m.fsys != nil {
+		m.fsys.mu.Lock()
+	}
+}
+
+func (m *FakeFileDescriptor) unlock() {
+	if m.fsys != nil {
+		m.fsys.mu.Unlock()
+	}
+}
 
 func (m *FakeFileDescriptor) Close() error {
+	m.lock()
+	defer m.unlock()
 	if m.closed {
 		return errors.New("invalid argument")
 	}
 	m.closed = true
+	m.compress()
+	m.unlockFile()
+	if m.fsys != nil {
+		m.fsys.openFiles--
+		m.fsys.syncClose(m)
+		m.file.opens--
+		if m.file.opens == 0 {
+			delete(m.fsys.orphans, m.file.inode) // its space is freed
+		}
+	}
+	return nil
+}
+
+// Sync commits the contents of the file, modeling fsync(2): with crash
+// simulation, they are made durable.
+func (m *FakeFileDescriptor) Sync() error {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("Sync", "sync"); err != nil {
+		return err
+	}
+	if m.closed {
+		return &os.PathError{
+			Op:   "sync",
+			Path: m.file.path,
+			Err:  os.ErrClosed,
+		}
+	}
+	m.compress()
+	if m.fsys != nil && m.fsys.crashSim {
+		m.file.sync()
+	}
+	m.synced = true
 	return nil
 }
 
+// Synced tells whether Sync was called on this descriptor.
+func (m *FakeFileDescriptor) Synced() bool {
+	m.lock()
+	defer m.unlock()
+	return m.synced
+}
+
 func (m *FakeFileDescriptor) Name() string {
+	if m.info != nil {
+		return m.info.name
+	}
+	m.lock()
+	defer m.unlock()
 	return m.file.name
 }
 
 func (m *FakeFileDescriptor) Stat() (fs.FileInfo, error) {
+	m.lock()
+	defer m.unlock()
 	if m.closed {
 		return nil, &os.PathError{
 			Op:   "stat",
@@ -502,11 +2102,17 @@ func (m *FakeFileDescriptor) Stat() (fs.FileInfo, error) {
 			Err:  errors.New("use of closed file"),
 		}
 	}
-	// m implements fs.FileInfo
-	return m, nil
+	// the descriptor keeps referring to the file, even after it's removed
+	// (then with a link count of 0)
+	return m.snapshot(), nil
 }
 
 func (m *FakeFileDescriptor) Read(b []byte) (n int, err error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("Read", "read"); err != nil {
+		return 0, err
+	}
 	if m.closed {
 		return 0, &os.PathError{
 			Op:   "stat",
@@ -514,22 +2120,201 @@ func (m *FakeFileDescriptor) Read(b []byte) (n int, err error) {
 			Err:  errors.New("file already closed"),
 		}
 	}
-	if m.file.isDir || (m.flag&0b11) == os.O_WRONLY {
+	if m.file.isDir {
+		return 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  syscall.EISDIR,
+		}
+	}
+	if !canRead(m.flag) {
 		return 0, &os.PathError{
 			Op:   "read",
 			Path: m.file.path,
-			Err:  syscall.EISDIR, // @fixme: wronly returns EISDIR? (check if this is correct)
+			Err:  syscall.EBADF,
 		}
 	}
-	if m.cursor >= int64(len(m.file.bytes)) {
+	m.accessed()
+	bs := m.data()
+	if m.cursor >= int64(len(bs)) {
 		return 0, io.EOF
 	}
-	n = copy(b, m.file.bytes[m.cursor:])
+	n = copy(b, bs[m.cursor:])
 	m.cursor += int64(n)
 	return
 }
 
+// ReadDir reads the entries of the directory, sorted by name, like
+// os.File.ReadDir: if n > 0, it returns at most n entries, continuing where
+// the previous call left off, and io.EOF once there are none left; if
+// n <= 0, it returns all remaining entries, with a nil error.
+// The listing is a snapshot taken by the first call, seeking back to the
+// start takes a new one.
+func (m *FakeFileDescriptor) ReadDir(n int) ([]fs.DirEntry, error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("ReadDir", "readdirent"); err != nil {
+		return nil, err
+	}
+	if m.closed {
+		return nil, &os.PathError{
+			Op:   "readdirent",
+			Path: m.file.path,
+			Err:  errors.New("file already closed"),
+		}
+	}
+	if !m.file.isDir {
+		return nil, &os.PathError{
+			Op:   "readdirent",
+			Path: m.file.path,
+			Err:  syscall.ENOTDIR,
+		}
+	}
+	if m.cursor == 0 {
+		if m.fsys != nil {
+			m.fsys.record("readdir", m.file.path)
+		}
+		children := readDir(m.file)
+		m.dirEntries = make([]fs.DirEntry, len(children))
+		for i, f := range children {
+			if m.fsys != nil {
+				m.dirEntries[i] = m.fsys.dirEntry(f)
+			} else {
+				m.dirEntries[i] = &FakeFileDescriptor{
+					file: f,
+					flag: os.O_RDONLY,
+					info: f.snapshot(),
+				}
+			}
+		}
+	}
+	// the cursor of a directory is the index of the next entry
+	var rest []fs.DirEntry
+	if m.cursor < int64(len(m.dirEntries)) {
+		rest = m.dirEntries[m.cursor:]
+	}
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	m.cursor += int64(len(rest))
+	return append([]fs.DirEntry{}, rest...), nil
+}
+
+// ReadAt reads len(b) bytes starting at the absolute offset off, without
+// moving the cursor. If fewer than len(b) bytes are available, io.EOF is
+// returned along with the bytes read.
+func (m *FakeFileDescriptor) ReadAt(b []byte, off int64) (n int, err error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("ReadAt", "read"); err != nil {
+		return 0, err
+	}
+	if m.closed {
+		return 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  errors.New("file already closed"),
+		}
+	}
+	if off < 0 {
+		return 0, &os.PathError{
+			Op:   "readat",
+			Path: m.file.path,
+			Err:  errors.New("negative offset"),
+		}
+	}
+	if m.file.isDir {
+		return 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  syscall.EISDIR,
+		}
+	}
+	if !canRead(m.flag) {
+		return 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  syscall.EBADF,
+		}
+	}
+	m.accessed()
+	bs := m.data()
+	if off >= int64(len(bs)) {
+		return 0, io.EOF
+	}
+	n = copy(b, bs[off:])
+	if n < len(b) {
+		err = io.EOF
+	}
+	return
+}
+
+// WriteTo writes the remaining contents, from the cursor up to the end of
+// the file, to w in a single call, and advances the cursor accordingly.
+// It lets io.Copy skip its intermediate buffer.
+func (m *FakeFileDescriptor) WriteTo(w io.Writer) (n int64, err error) {
+	// w is written to without holding the lock, since it may itself belong to
+	// the file system
+	bs, cursor, err := m.remaining()
+	if err != nil || len(bs) == 0 {
+		return 0, err
+	}
+	nw, err := w.Write(bs)
+	m.lock()
+	m.cursor = cursor + int64(nw)
+	m.unlock()
+	return int64(nw), err
+}
+
+// remaining returns a copy of the contents from the cursor up to the end of
+// the file, along with the cursor.
+func (m *FakeFileDescriptor) remaining() ([]byte, int64, error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("Read", "read"); err != nil {
+		return nil, 0, err
+	}
+	if m.closed {
+		return nil, 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  errors.New("file already closed"),
+		}
+	}
+	if m.file.isDir {
+		return nil, 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  syscall.EISDIR,
+		}
+	}
+	if !canRead(m.flag) {
+		return nil, 0, &os.PathError{
+			Op:   "read",
+			Path: m.file.path,
+			Err:  syscall.EBADF,
+		}
+	}
+	m.accessed()
+	bs := m.data()
+	if m.cursor >= int64(len(bs)) {
+		return nil, m.cursor, nil
+	}
+	return append([]byte(nil), bs[m.cursor:]...), m.cursor, nil
+}
+
 func (m *FakeFileDescriptor) Write(src []byte) (n int, err error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("Write", "write"); err != nil {
+		return 0, err
+	}
+	if m.fsys != nil && m.fsys.readOnly {
+		return 0, erofs("write", m.file.path)
+	}
 	if m.closed {
 		return 0, &os.PathError{
 			Op:   "stat",
@@ -537,36 +2322,120 @@ func (m *FakeFileDescriptor) Write(src []byte) (n int, err error) {
 			Err:  errors.New("file already closed"),
 		}
 	}
-	if m.file.isDir || (m.flag&0b11) == os.O_RDONLY {
+	if m.file.isDir || !canWrite(m.flag) {
 		return 0, &os.PathError{
 			Op:   "write",
 			Path: m.file.path,
 			Err:  syscall.EBADF,
 		}
 	}
-	if (m.flag & os.O_APPEND) == 1 {
-		m.cursor = int64(len(m.file.bytes))
-	} else {
-		for m.cursor > int64(len(m.file.bytes)) {
-			m.file.bytes = append(m.file.bytes, 0)
+	if (m.flag & os.O_APPEND) != 0 {
+		m.cursor = int64(len(m.data()))
+	}
+	if err := m.checkSpace(len(src), m.cursor); err != nil {
+		return 0, err
+	}
+	n = m.write(src, m.cursor)
+	m.cursor += int64(n)
+	return
+}
+
+// WriteAt writes len(b) bytes starting at the absolute offset off, without
+// moving the cursor. A gap between the end of the file and off is filled
+// with zeros.
+func (m *FakeFileDescriptor) WriteAt(src []byte, off int64) (n int, err error) {
+	m.lock()
+	defer m.unlock()
+	if err := m.injected("WriteAt", "write"); err != nil {
+		return 0, err
+	}
+	if m.fsys != nil && m.fsys.readOnly {
+		return 0, erofs("write", m.file.path)
+	}
+	if m.closed {
+		return 0, &os.PathError{
+			Op:   "write",
+			Path: m.file.path,
+			Err:  errors.New("file already closed"),
+		}
+	}
+	if (m.flag & os.O_APPEND) != 0 {
+		return 0, errors.New("os: invalid use of WriteAt on file opened with O_APPEND")
+	}
+	if off < 0 {
+		return 0, &os.PathError{
+			Op:   "writeat",
+			Path: m.file.path,
+			Err:  errors.New("negative offset"),
+		}
+	}
+	if m.file.isDir || !canWrite(m.flag) {
+		return 0, &os.PathError{
+			Op:   "write",
+			Path: m.file.path,
+			Err:  syscall.EBADF,
 		}
 	}
-	dst := m.file.bytes[m.cursor:]
+	if err := m.checkSpace(len(src), off); err != nil {
+		return 0, err
+	}
+	return m.write(src, off), nil
+}
+
+// write writes src at the offset off, returning how many bytes were written.
+func (m *FakeFileDescriptor) write(src []byte, off int64) (n int) {
+	bs := m.data()
+	for off > int64(len(bs)) {
+		bs = append(bs, 0)
+	}
+	dst := bs[off:]
 	if len(src) <= len(dst) { // enough space in file for new data
 		n = copy(dst, src)
 	} else { // not enough space, we are appending (and possibly overwriting the end of the file)
 		//          current len + amount missing
-		nl := len(m.file.bytes) + len(src) - len(dst)
+		nl := len(bs) + len(src) - len(dst)
 		nbs := make([]byte, nl)
-		copy(nbs, m.file.bytes[:m.cursor])
-		n = copy(nbs[m.cursor:], src)
-		m.file.bytes = nbs
+		copy(nbs, bs[:off])
+		n = copy(nbs[off:], src)
+		bs = nbs
 	}
-	m.cursor += int64(n)
+	m.setData(bs)
+	m.file.modified()
 	return
 }
 
+// ReadFrom reads r until EOF, writing everything at the cursor, as if by a
+// single call to Write.
+// It lets io.Copy skip its intermediate buffer.
+func (m *FakeFileDescriptor) ReadFrom(r io.Reader) (n int64, err error) {
+	m.lock()
+	closed, isDir := m.closed, m.file.isDir
+	m.unlock()
+	if closed {
+		return 0, &os.PathError{
+			Op:   "write",
+			Path: m.file.path,
+			Err:  errors.New("file already closed"),
+		}
+	}
+	if isDir || !canWrite(m.flag) {
+		return 0, &os.PathError{
+			Op:   "write",
+			Path: m.file.path,
+			Err:  syscall.EBADF,
+		}
+	}
+	data, rerr := io.ReadAll(r)
+	nw, err := m.Write(data)
+	if err == nil {
+		err = rerr
+	}
+	return int64(nw), err
+}
+
 func (m *FakeFileDescriptor) Seek(offset int64, whence int) (int64, error) {
+	m.lock()
+	defer m.unlock()
 	if m.closed {
 		return 0, &os.PathError{
 			Op:   "stat",
@@ -574,48 +2443,111 @@ func (m *FakeFileDescriptor) Seek(offset int64, whence int) (int64, error) {
 			Err:  errors.New("file already closed"),
 		}
 	}
+	var cursor int64
 	switch whence {
 	case io.SeekStart:
 		// relative to the origin of the file
-		m.cursor = offset
+		cursor = offset
 	case io.SeekCurrent:
 		// relative to the current offset
-		m.cursor += offset
+		cursor = m.cursor + offset
 	case io.SeekEnd:
 		// relative to the end of the file
-		m.cursor = int64(len(m.file.bytes)) + offset
+		cursor = int64(len(m.data())) + offset
+	default:
+		cursor = -1 // invalid whence
+	}
+	// seeking past the end is fine, but not before the beginning
+	if cursor < 0 {
+		return 0, &os.PathError{
+			Op:   "seek",
+			Path: m.file.path,
+			Err:  syscall.EINVAL,
+		}
 	}
+	m.cursor = cursor
 	return m.cursor, nil
 }
 
 func (m *FakeFileDescriptor) Info() (fs.FileInfo, error) {
 	// "The returned FileInfo may be from the time of the original directory read [...]"
 	// -- go doc fs.DirEntry
-	// m implements fs.FileInfo
-	return m, nil
+	if m.recorder != nil {
+		m.recorder.record("stat", m.path)
+	}
+	if m.info != nil {
+		return m.info, nil
+	}
+	m.lock()
+	defer m.unlock()
+	return m.snapshot(), nil
+}
+
+// snapshot describes the file as seen through this descriptor.
+func (m *FakeFileDescriptor) snapshot() *fakeFileInfo {
+	fi := m.file.snapshot()
+	if m.gzipped {
+		fi.size = int64(len(m.decompressed))
+	}
+	return fi
 }
 
 func (m *FakeFileDescriptor) IsDir() bool {
+	if m.info != nil {
+		return m.info.isDir
+	}
 	return m.file.isDir
 }
 
+// accessed records a read through this descriptor.
+func (m *FakeFileDescriptor) accessed() {
+	if m.fsys != nil {
+		m.fsys.accessed(m.file)
+	} else {
+		m.file.accessed()
+	}
+}
+
+// AccessTime reports the time the file was last read (its atime).
+func (m *FakeFileDescriptor) AccessTime() time.Time {
+	m.lock()
+	defer m.unlock()
+	return m.file.lastAccess
+}
+
+// Type is cheap: it's available straight from the directory read, as opposed
+// to Info, which may need to stat the file (and is recorded as such, see
+// Record).
 func (m *FakeFileDescriptor) Type() fs.FileMode {
-	return m.file.mode
+	if m.info != nil {
+		return m.info.mode.Type()
+	}
+	m.lock()
+	defer m.unlock()
+	return m.file.fileMode().Type()
 }
 
 func (m *FakeFileDescriptor) ModTime() time.Time {
+	m.lock()
+	defer m.unlock()
 	return m.file.lastMod
 }
 
 func (m *FakeFileDescriptor) Mode() fs.FileMode {
-	return m.file.mode
+	m.lock()
+	defer m.unlock()
+	return m.file.fileMode()
 }
 
 func (m *FakeFileDescriptor) Size() int64 {
-	return int64(len(m.file.bytes))
+	m.lock()
+	defer m.unlock()
+	return m.file.size()
 }
 
 func (m *FakeFileDescriptor) Sys() any {
+	m.lock()
+	defer m.unlock()
 	if m.closed {
 		return &os.PathError{
 			Op:   "stat",
@@ -623,7 +2555,8 @@ func (m *FakeFileDescriptor) Sys() any {
 			Err:  errors.New("file already closed"),
 		}
 	}
-	return m.file
+	// on Linux, a *syscall.Stat_t (e.g., for the ctime)
+	return m.file.stat()
 }
 
 func MockFS(opts ...FSOption) (fs *FakeFileSystem) {
@@ -631,14 +2564,14 @@ func MockFS(opts ...FSOption) (fs *FakeFileSystem) {
 		isDir:    true,
 		path:     "/",
 		name:     "/",
-		mode:     0777 - umask,
-		lastMod:  Time(),
+		inode:    newInode(0777 &^ umask),
 		parent:   nil,
 		children: map[string]*FakeFile{},
 	}
 	fs = &FakeFileSystem{
 		parent: r,
 		root:   r,
+		cwd:    "/",
 		contents: map[string]*FakeFile{
 			"/": r,
 		},
@@ -646,11 +2579,41 @@ func MockFS(opts ...FSOption) (fs *FakeFileSystem) {
 	for _, opt := range opts {
 		opt(fs)
 	}
+	// set up contents are durable
+	fs.Sync()
 	return
 }
 
 type FSOption func(*FakeFileSystem)
 
+// WithNewFileTemplate initializes files newly created through Create (or
+// OpenFile with O_CREATE) with a copy of data, instead of leaving them empty.
+// Existing files that are truncated, and files created by WriteFile, are not
+// affected.
+func WithNewFileTemplate(data []byte) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.newFileTemplate = append([]byte{}, data...)
+	}
+}
+
+// WithNoAtime models a file system mounted with noatime: reading a file
+// (Read, ReadAt, ReadFile, ...) doesn't advance its access time.
+// Explicitly setting it with Chtimes still works.
+func WithNoAtime() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.noAtime = true
+	}
+}
+
+// WithMaxOpenFiles limits the number of concurrently open descriptors.
+// Once n descriptors are open, Open, Create, and OpenFile fail with EMFILE
+// until some of them are closed.
+func WithMaxOpenFiles(n int) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.maxOpenFiles = n
+	}
+}
+
 func WithFile(path string, data []byte) FSOption {
 	return func(fs *FakeFileSystem) {
 		path := filepath.Clean(path)
@@ -666,9 +2629,8 @@ func WithFile(path string, data []byte) FSOption {
 				pn = &FakeFile{
 					isDir:    true,
 					path:     pname,
-					name:     parts[i] + "/",
-					mode:     0777 - umask,
-					lastMod:  Time(),
+					name:     parts[i],
+					inode:    fs.newInode(0777 &^ umask),
 					parent:   p,
 					children: map[string]*FakeFile{},
 				}
@@ -680,14 +2642,13 @@ func WithFile(path string, data []byte) FSOption {
 		// p now points to the file's immediate ancestor
 
 		f := &FakeFile{
-			isDir:   false,
-			path:    path,
-			name:    filepath.Base(path),
-			bytes:   data,
-			mode:    0666 - umask,
-			lastMod: Time(),
-			parent:  p,
+			isDir:  false,
+			path:   path,
+			name:   filepath.Base(path),
+			inode:  fs.newInode(0666 &^ umask),
+			parent: p,
 		}
+		f.bytes = data
 		p.children[path] = f
 		fs.contents[path] = f
 	}
@@ -707,9 +2668,8 @@ func WithDirectory(path string) FSOption {
 				pn = &FakeFile{
 					isDir:    true,
 					path:     pname,
-					name:     parts[i] + "/",
-					mode:     0777 - umask,
-					lastMod:  Time(),
+					name:     parts[i],
+					inode:    fs.newInode(0777 &^ umask),
 					parent:   p,
 					children: map[string]*FakeFile{},
 				}
@@ -723,6 +2683,8 @@ func WithDirectory(path string) FSOption {
 }
 
 func (m *FakeFileSystem) String() (pp string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	ns := []*FakeFile{m.root}
 	for len(ns) > 0 {
 		n := ns[0]
@@ -731,12 +2693,19 @@ func (m *FakeFileSystem) String() (pp string) {
 		var content string
 		if n.isDir {
 			content = "(Directory)"
+		} else if n.symlink {
+			content = "(Symlink -> " + n.linkTarget + ")"
 		} else {
 			content = "`" + string(n.bytes) + "'"
 		}
 		pp = fmt.Sprintf("%s\n%s: %s", pp, n.path, content)
 
-		ns = append(ns, maps.Values(n.children)...)
+		// in order, so that the output can be compared
+		names := maps.Keys(n.children)
+		sort.Strings(names)
+		for _, name := range names {
+			ns = append(ns, n.children[name])
+		}
 	}
 	return
 }
@@ -1,11 +1,21 @@
 package ffs
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 // @todo: many more tests needed to test the correct (complicated) behaviour
@@ -122,8 +132,8 @@ func TestStat(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if fi.Mode() != testPerm-umask {
-		t.Errorf("got: %o, want: %o", fi.Mode(), testPerm-umask)
+	if fi.Mode() != testPerm&^umask {
+		t.Errorf("got: %o, want: %o", fi.Mode(), testPerm&^umask)
 	}
 	if fi.Name() != testFileName {
 		t.Errorf("got: `%s', want: `%s'", fi.Name(), testFileName)
@@ -405,8 +415,8 @@ func TestFile_Stat(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if fi.Mode() != testPerm-umask {
-		t.Errorf("got: %o, want: %o", fi.Mode(), testPerm-umask)
+	if fi.Mode() != testPerm&^umask {
+		t.Errorf("got: %o, want: %o", fi.Mode(), testPerm&^umask)
 	}
 	if fi.Name() != testFileName {
 		t.Errorf("got: `%s', want: `%s'", fi.Name(), testFileName)
@@ -466,6 +476,23 @@ func TestRemoveDirNonEmpty(t *testing.T) {
 	}
 }
 
+func TestRemoveErrNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile("/a", []byte(testContent)),
+		WithDirectory("/d"),
+	)
+	for _, path := range []string{"/a/b", "/a/b/c"} {
+		err := m.Remove(path)
+		if !errors.Is(err, syscall.ENOTDIR) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.ENOTDIR)
+		}
+	}
+	err := m.Remove("/d/b")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
 func TestRemoveAll(t *testing.T) {
 	m := MockFS(
 		WithFile(testFilePath, []byte(testContent)),
@@ -488,6 +515,40 @@ func TestRemoveAll(t *testing.T) {
 	}
 }
 
+func TestRemoveAllDeepTree(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c/d/e", nil),
+		WithFile("/a/b/c/f", nil),
+		WithFile("/a/g", nil),
+		WithDirectory("/a/h/i"),
+	)
+	if err := m.RemoveAll("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.contents) != 1 || m.contents["/"] != m.root {
+		t.Errorf("got: `%v', want: only `/'", maps.Keys(m.contents))
+	}
+	if len(m.root.children) != 0 {
+		t.Errorf("got: `%v', want: no children", maps.Keys(m.root.children))
+	}
+	if err := m.RemoveAll("/"); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EPERM)
+	}
+}
+
+func TestRemoveAllMissing(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.RemoveAll("/does/not/exist"); err != nil {
+		t.Errorf("got: `%v', want: `<nil>'", err)
+	}
+	// other failures are still reported
+	if err := m.RemoveAll(testFilePath + "/child"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+}
+
 func TestWalkDir(t *testing.T) {
 	m := MockFS(
 		WithFile("/tmp/t/1", []byte("")),
@@ -528,6 +589,33 @@ func TestWalkDir(t *testing.T) {
 	}
 }
 
+func TestWalkDirRootPrefix(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c/d", nil),
+		WithFile("/a/b/e", nil),
+	)
+	for root, clean := range map[string]string{
+		"/a/../a/b": "/a/b",
+		"b/c/..":    "b", // relative to the working directory
+	} {
+		if err := m.Chdir("/a"); err != nil {
+			t.Fatal(err)
+		}
+		var visited []string
+		err := m.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			visited = append(visited, path)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{clean, clean + "/c", clean + "/c/d", clean + "/e"}
+		if !slices.Equal(visited, expected) {
+			t.Errorf("%s: got: `%v', want: `%v'", root, visited, expected)
+		}
+	}
+}
+
 func TestWalkDirSkipOnDir(t *testing.T) {
 	m := MockFS(
 		WithFile("/tmp/t/1", []byte("")),
@@ -566,6 +654,61 @@ func TestWalkDirSkipOnDir(t *testing.T) {
 	}
 }
 
+func TestWalkDirRemoveDuringWalk(t *testing.T) {
+	m := MockFS(
+		WithFile("/tmp/t/1", []byte("")),
+		WithFile("/tmp/t/3", []byte("")),
+		WithFile("/tmp/t/2/4", []byte("")),
+		WithFile("/tmp/t/2/5", []byte("")),
+		WithFile("/tmp/t/2/6/7", []byte("")),
+	)
+
+	expected, visited := []string{
+		"/tmp/t",
+		"/tmp/t/1",
+		"/tmp/t/2",
+		"/tmp/t/2/4",
+		"/tmp/t/3",
+	}, []string{}
+
+	err := m.WalkDir("/tmp/t", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, path)
+		if path == "/tmp/t/2/4" {
+			// empty out the directory, including the entry being visited
+			if err := m.RemoveAll("/tmp/t/2/6"); err != nil {
+				return err
+			}
+			for _, p := range []string{"/tmp/t/2/4", "/tmp/t/2/5"} {
+				if err := m.Remove(p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expected) != len(visited) {
+		t.Fatalf("visited: `%v', expected: `%v'", visited, expected)
+	}
+	for i := range expected {
+		if expected[i] != visited[i] {
+			t.Errorf("got: `%s', want: `%s'", visited[i], expected[i])
+		}
+	}
+	for _, path := range []string{"/tmp/t/2/4", "/tmp/t/2/5", "/tmp/t/2/6"} {
+		_, err := m.Stat(path)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, os.ErrNotExist)
+		}
+	}
+}
+
 func TestWalkDirSkipWithinDir(t *testing.T) {
 	m := MockFS(
 		WithFile("/tmp/t/1", []byte("")),
@@ -691,3 +834,2319 @@ func TestFile_SeekPastEndAndWrite(t *testing.T) {
 		t.Errorf("got: `%s', want: `%s'", bs, expectedResult)
 	}
 }
+
+func TestWalkDirEntriesPointInTime(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	var entry fs.DirEntry
+	err := m.WalkDir(testFileDir, func(path string, d fs.DirEntry, err error) error {
+		if path == testFilePath {
+			entry = d
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatalf("`%s' not visited", testFilePath)
+	}
+	err = m.Truncate(testFilePath, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const newPath = "/Classified/Renamed.txt"
+	err = m.Rename(testFilePath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name() != testFileName {
+		t.Errorf("got: `%s', want: `%s'", entry.Name(), testFileName)
+	}
+	fi, err := entry.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != testFileName {
+		t.Errorf("got: `%s', want: `%s'", fi.Name(), testFileName)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+}
+
+func TestWalkDirEntrySnapshot(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	var entry fs.DirEntry
+	err := m.WalkDir(testFileDir, func(path string, d fs.DirEntry, err error) error {
+		if path == testFilePath {
+			entry = d
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatalf("`%s' not visited", testFilePath)
+	}
+	err = m.WriteFile(testFilePath, []byte("Giraffe > Greif"), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := entry.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+}
+
+func TestOpenFileAppendCursorAtEOF(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := fd.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", ret, len(testContent))
+	}
+}
+
+func TestFile_WriteAppend(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writes go to the end of the file, regardless of the cursor
+	_, err = fd.Write([]byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Seek(2, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte("def"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testContent + "abcdef"
+	if string(bs) != want {
+		t.Errorf("got: `%s', want: `%s'", bs, want)
+	}
+}
+
+func TestWriteFileOnDirectoryMatchesOS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("compares against linux behaviour")
+	}
+	var real RealFileSystem
+	realErr := real.WriteFile(t.TempDir(), []byte(testContent), testPerm)
+	var realPathErr *os.PathError
+	if !errors.As(realErr, &realPathErr) {
+		t.Fatalf("got: `%v', want: *os.PathError", realErr)
+	}
+
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	err := m.WriteFile(testFileDir, []byte(testContent), testPerm)
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("got: `%v', want: *os.PathError", err)
+	}
+	if pathErr.Op != realPathErr.Op {
+		t.Errorf("got: `%s', want: `%s'", pathErr.Op, realPathErr.Op)
+	}
+	if pathErr.Err != realPathErr.Err {
+		t.Errorf("got: `%v', want: `%v'", pathErr.Err, realPathErr.Err)
+	}
+	if pathErr.Path != testFileDir {
+		t.Errorf("got: `%s', want: `%s'", pathErr.Path, testFileDir)
+	}
+}
+
+func TestFile_AccessModeErrno(t *testing.T) {
+	const dirPath = "/Classified"
+	cases := []struct {
+		flag  int
+		write bool
+		isDir bool
+		want  error // nil means the operation succeeds
+	}{
+		{os.O_RDONLY, false, false, nil},
+		{os.O_RDONLY, true, false, syscall.EBADF},
+		{os.O_WRONLY, false, false, syscall.EBADF},
+		{os.O_WRONLY, true, false, nil},
+		{os.O_RDWR, false, false, nil},
+		{os.O_RDWR, true, false, nil},
+		{os.O_RDONLY, false, true, syscall.EISDIR},
+		{os.O_RDONLY, true, true, syscall.EBADF},
+		{os.O_WRONLY, false, true, syscall.EISDIR},
+		{os.O_WRONLY, true, true, syscall.EBADF},
+		{os.O_RDWR, false, true, syscall.EISDIR},
+		{os.O_RDWR, true, true, syscall.EBADF},
+	}
+	for _, c := range cases {
+		m := MockFS(
+			WithFile(testFilePath, []byte(testContent)),
+		)
+		path := testFilePath
+		if c.isDir {
+			path = dirPath
+		}
+		// descriptors with write access to a directory can't be obtained
+		// through OpenFile, so we construct them directly
+		fd := &FakeFileDescriptor{
+			file: m.contents[path],
+			flag: c.flag,
+		}
+		var err error
+		if c.write {
+			_, err = fd.Write([]byte("1234"))
+		} else {
+			_, err = fd.Read(make([]byte, 4))
+		}
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("flag: %d, write: %t, isDir: %t: got: `%v', want: <nil>", c.flag, c.write, c.isDir, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.want) {
+			t.Errorf("flag: %d, write: %t, isDir: %t: got: `%v', want: `%v'", c.flag, c.write, c.isDir, err, c.want)
+		}
+	}
+}
+
+func TestRenameErrNotExist(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	const newPath = "/Classified/Renamed.txt"
+	err := m.Rename(testFilePath, newPath)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("got: `%v', want: *os.LinkError", err)
+	}
+	if linkErr.Old != testFilePath {
+		t.Errorf("got: `%s', want: `%s'", linkErr.Old, testFilePath)
+	}
+	if linkErr.New != newPath {
+		t.Errorf("got: `%s', want: `%s'", linkErr.New, newPath)
+	}
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestRenameDestinationParentErrNotExist(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	const newPath = "/Declassified/Fakenius.txt"
+	err := m.Rename(testFilePath, newPath)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("got: `%v', want: *os.LinkError", err)
+	}
+	if linkErr.Old != testFilePath {
+		t.Errorf("got: `%s', want: `%s'", linkErr.Old, testFilePath)
+	}
+	if linkErr.New != newPath {
+		t.Errorf("got: `%s', want: `%s'", linkErr.New, newPath)
+	}
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	// the source must be left untouched
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestRename(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	const newPath = "/Classified/Renamed.txt"
+	err := m.Rename(testFilePath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Open(testFilePath)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got: `%v', want: `%v'", err, os.ErrNotExist)
+	}
+	fd, err := m.Open(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fd.Name() != "Renamed.txt" {
+		t.Errorf("got: `%s', want: `%s'", fd.Name(), "Renamed.txt")
+	}
+}
+
+func TestRenameOverwrite(t *testing.T) {
+	const newPath = "/Classified/Renamed.txt"
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile(newPath, []byte("Giraffe > Greif")),
+	)
+	err := m.Rename(testFilePath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	_, err = m.Stat(testFilePath)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got: `%v', want: `%v'", err, os.ErrNotExist)
+	}
+}
+
+func TestRenameDestinationParentErrNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/a", []byte(testContent)),
+	)
+	for _, newPath := range []string{"/a/b", "/a/b/c"} {
+		err := m.Rename(testFilePath, newPath)
+		if !errors.Is(err, syscall.ENOTDIR) {
+			t.Errorf("%s: got: `%v', want: `%v'", newPath, err, syscall.ENOTDIR)
+		}
+	}
+}
+
+func TestRenameDirectory(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c.txt", []byte(testContent)),
+		WithDirectory("/a/d"),
+	)
+	err := m.Rename("/a", "/e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/a", "/a/b", "/a/b/c.txt", "/a/d"} {
+		_, err := m.Stat(path)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, os.ErrNotExist)
+		}
+	}
+	for _, path := range []string{"/e", "/e/b", "/e/b/c.txt", "/e/d"} {
+		_, err := m.Stat(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	bs, err := m.ReadFile("/e/b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestFile_WriteAfterTruncate(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	fd, err := m.Create(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("a"), 100)
+	n, err := fd.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) {
+		t.Errorf("got: %d, want: %d", n, len(data))
+	}
+
+	err = m.Truncate(testFilePath, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := fd.Seek(60, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 60 {
+		t.Errorf("got: %d, want: 60", ret)
+	}
+	n, err = fd.Write([]byte("xx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got: %d, want: 2", n)
+	}
+
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := string(data[:40]) + string(make([]byte, 20)) + "xx"
+	if len(bs) != 62 {
+		t.Errorf("got: %d, want: 62", len(bs))
+	}
+	if string(bs) != expected {
+		t.Errorf("got: `%q', want: `%q'", bs, expected)
+	}
+}
+
+func TestTruncateGrow(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte("abc")),
+	)
+	err := m.Truncate(testFilePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 10 {
+		t.Errorf("got: %d, want: 10", fi.Size())
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "abc" + string(make([]byte, 7))
+	if string(bs) != expected {
+		t.Errorf("got: `%q', want: `%q'", bs, expected)
+	}
+
+	err = m.Truncate(testFilePath, -1)
+	if !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EINVAL)
+	}
+}
+
+func TestCreateErrNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile("/a", []byte(testContent)),
+	)
+	for _, path := range []string{"/a/b", "/a/b/c"} {
+		_, err := m.Create(path)
+		if !errors.Is(err, syscall.ENOTDIR) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.ENOTDIR)
+		}
+	}
+}
+
+func TestCreateErrNotExist(t *testing.T) {
+	m := MockFS()
+	_, err := m.Create("/a/b/c")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestWalkDirDepth(t *testing.T) {
+	m := MockFS(
+		WithFile("/tmp/t/1", []byte("")),
+		WithFile("/tmp/t/2/3", []byte("")),
+		WithFile("/tmp/t/2/4/5", []byte("")),
+		WithFile("/tmp/t/2/4/6/7", []byte("")),
+	)
+
+	cases := []struct {
+		maxDepth int
+		expected []string
+	}{
+		{1, []string{
+			"/tmp/t",
+			"/tmp/t/1",
+			"/tmp/t/2",
+		}},
+		{2, []string{
+			"/tmp/t",
+			"/tmp/t/1",
+			"/tmp/t/2",
+			"/tmp/t/2/3",
+			"/tmp/t/2/4",
+		}},
+	}
+	for _, c := range cases {
+		visited := []string{}
+		err := m.WalkDirDepth("/tmp/t", c.maxDepth, func(path string, d fs.DirEntry, err error) error {
+			visited = append(visited, path)
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(c.expected) != len(visited) {
+			t.Errorf("maxDepth %d: got: `%v', want: `%v'", c.maxDepth, visited, c.expected)
+			continue
+		}
+		for i := range c.expected {
+			if c.expected[i] != visited[i] {
+				t.Errorf("maxDepth %d: got: `%s', want: `%s'", c.maxDepth, visited[i], c.expected[i])
+			}
+		}
+	}
+}
+
+func TestStatModeType(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	cases := []struct {
+		name string
+		file *FakeFile
+		want fs.FileMode
+	}{
+		{"regular", m.contents[testFilePath], 0},
+		{"directory", m.contents["/Classified"], fs.ModeDir},
+		{"root", m.contents["/"], fs.ModeDir},
+		{"symlink", &FakeFile{inode: &inode{mode: fs.ModeSymlink | 0777}}, fs.ModeSymlink},
+		{"named pipe", &FakeFile{inode: &inode{mode: fs.ModeNamedPipe | 0644}}, fs.ModeNamedPipe},
+		{"socket", &FakeFile{inode: &inode{mode: fs.ModeSocket | 0755}}, fs.ModeSocket},
+		{"block device", &FakeFile{inode: &inode{mode: fs.ModeDevice | 0660}}, fs.ModeDevice},
+		{"char device", &FakeFile{inode: &inode{mode: fs.ModeDevice | fs.ModeCharDevice | 0660}}, fs.ModeDevice | fs.ModeCharDevice},
+		{"unknown", &FakeFile{inode: &inode{mode: fs.ModeSymlink | fs.ModeSocket | 0644}}, fs.ModeIrregular},
+	}
+	for _, c := range cases {
+		fd := &FakeFileDescriptor{file: c.file}
+		if got := fd.Mode().Type(); got != c.want {
+			t.Errorf("%s: got: `%v', want: `%v'", c.name, got, c.want)
+		}
+		if got := fd.Type(); got != c.want {
+			t.Errorf("%s: got: `%v', want: `%v'", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCreateAll(t *testing.T) {
+	m := MockFS()
+	fd, err := m.CreateAll("/x/y/z.txt", testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/x", "/x/y"} {
+		fi, err := m.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.IsDir() {
+			t.Errorf("%s: got: `IsDir = false', want: `IsDir = true'", dir)
+		}
+	}
+	n, err := fd.Write([]byte(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(testContent) {
+		t.Errorf("got: %d, want: %d", n, len(testContent))
+	}
+	bs, err := m.ReadFile("/x/y/z.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestCreateAllErrNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile("/x", []byte(testContent)),
+	)
+	_, err := m.CreateAll("/x/y/z.txt", testPerm)
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+}
+
+func TestReadFileCopies(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs[0] = '_'
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func benchmarkReadFile(b *testing.B, read func(*FakeFileSystem, string) ([]byte, error), opts ...FSOption) {
+	m := MockFS(append(opts,
+		WithFile(testFilePath, bytes.Repeat([]byte(testContent), 1<<14)),
+	)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := read(m, testFilePath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	benchmarkReadFile(b, (*FakeFileSystem).ReadFile)
+}
+
+func BenchmarkReadFileNoCopy(b *testing.B) {
+	benchmarkReadFile(b, (*FakeFileSystem).ReadFileNoCopy)
+}
+
+func TestCreateAllFailureLeavesNoDirectories(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	// a trailing slash names a directory, which can't be created as a file
+	_, err := m.CreateAll("/new/dir/file/", testPerm)
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EISDIR)
+	}
+	if _, err := m.Lstat("/new"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	// existing parents are kept
+	_, err = m.CreateAll(testFileDir+"/new/file/", testPerm)
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EISDIR)
+	}
+	if _, err := m.Lstat(testFileDir + "/new"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Errorf("got: `%v', want: `<nil>'", err)
+	}
+}
+
+func TestCreateAllDescriptorStatMode(t *testing.T) {
+	for _, perm := range []fs.FileMode{0600, 0640, 0644, 0666, 0755, 0777, 0007} {
+		m := MockFS()
+		fd, err := m.CreateAll(testFilePath, perm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fd.Write([]byte(testContent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := perm &^ umask
+		fi, err := fd.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("perm %o: got: %o, want: %o", perm, fi.Mode().Perm(), want)
+		}
+		fi, err = m.Stat(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("perm %o: got: %o, want: %o", perm, fi.Mode().Perm(), want)
+		}
+	}
+}
+
+func TestWalkDirRel(t *testing.T) {
+	m := MockFS(
+		WithFile("/tmp/t/1", []byte("")),
+		WithFile("/tmp/t/2/3.txt", []byte("")),
+		WithFile("/tmp/t/2/4/5.txt", []byte("")),
+	)
+
+	expected, visited := []string{
+		".",
+		"1",
+		"2",
+		"2/3.txt",
+		"2/4",
+		"2/4/5.txt",
+	}, []string{}
+
+	err := m.WalkDirRel("/tmp/t/", func(rel string, d fs.DirEntry, err error) error {
+		visited = append(visited, rel)
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expected) != len(visited) {
+		t.Fatalf("got: `%v', want: `%v'", visited, expected)
+	}
+	for i := range expected {
+		if expected[i] != visited[i] {
+			t.Errorf("got: `%s', want: `%s'", visited[i], expected[i])
+		}
+	}
+}
+
+func TestFile_SectionReader(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+
+	const off, size = 9, 12
+	sr := io.NewSectionReader(fd.(io.ReaderAt), off, size)
+	bs := make([]byte, 128)
+	n, err := sr.Read(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testContent[off : off+size]
+	if string(bs[:n]) != expected {
+		t.Errorf("got: `%s', want: `%s'", bs[:n], expected)
+	}
+	n, err = sr.Read(bs)
+	if err != io.EOF {
+		t.Errorf("got: `%v', want: `%v'", err, io.EOF)
+	}
+	if n != 0 {
+		t.Errorf("got: %d, want: 0", n)
+	}
+
+	// the section reader must not have moved the descriptor's cursor
+	ret, err := fd.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 0 {
+		t.Errorf("got: %d, want: 0", ret)
+	}
+}
+
+func TestFile_WriteTo(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Seek(4, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testContent[4:]
+	if n != int64(len(expected)) {
+		t.Errorf("got: %d, want: %d", n, len(expected))
+	}
+	if buf.String() != expected {
+		t.Errorf("got: `%s', want: `%s'", buf.String(), expected)
+	}
+	ret, err := fd.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", ret, len(testContent))
+	}
+}
+
+func TestFile_ReadFrom(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	fd, err := m.Create(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(fd, strings.NewReader(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", n, len(testContent))
+	}
+	// strings.Reader implements io.WriterTo, which io.Copy prefers
+	n, err = fd.(io.ReaderFrom).ReadFrom(strings.NewReader(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", n, len(testContent))
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(2*len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), 2*len(testContent))
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent+testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent+testContent)
+	}
+}
+
+func benchmarkCopyInto(b *testing.B, dst func(File) io.Writer) {
+	data := bytes.Repeat([]byte(testContent), 1<<14)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := MockFS(
+			WithDirectory(testFileDir),
+		)
+		fd, err := m.Create(testFilePath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// hide bytes.Reader's io.WriterTo, so that io.Copy considers the destination
+		src := struct{ io.Reader }{bytes.NewReader(data)}
+		if _, err := io.Copy(dst(fd), src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFile_ReadFrom(b *testing.B) {
+	benchmarkCopyInto(b, func(fd File) io.Writer { return fd })
+}
+
+func BenchmarkFile_CopyGeneric(b *testing.B) {
+	benchmarkCopyInto(b, func(fd File) io.Writer { return struct{ io.Writer }{fd} })
+}
+
+func TestMkdirAllPreservesExistingModes(t *testing.T) {
+	m := MockFS()
+	err := m.Mkdir("/a", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.MkdirAll("/a/b/c", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]fs.FileMode{
+		"/a":     0700,
+		"/a/b":   0755,
+		"/a/b/c": 0755,
+	}
+	for path, perm := range expected {
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != perm {
+			t.Errorf("%s: got: %o, want: %o", path, fi.Mode().Perm(), perm)
+		}
+	}
+}
+
+func TestRemoveMatchesOS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("compares against linux behaviour")
+	}
+	var real RealFileSystem
+	tmp := t.TempDir()
+	for _, dir := range []string{"/empty", "/nonempty"} {
+		if err := os.Mkdir(tmp+dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/file", "/nonempty/file"} {
+		if err := os.WriteFile(tmp+file, []byte(testContent), testPerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := MockFS(
+		WithDirectory("/empty"),
+		WithFile("/nonempty/file", []byte(testContent)),
+		WithFile("/file", []byte(testContent)),
+	)
+
+	errno := func(err error) error {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return pathErr.Err
+		}
+		return err
+	}
+
+	for _, path := range []string{"/empty", "/nonempty", "/file", "/missing", "/file/child", "/missing/child"} {
+		want := errno(real.Remove(tmp + path))
+		got := errno(m.Remove(path))
+		if got != want {
+			t.Errorf("%s: got: `%v', want: `%v'", path, got, want)
+		}
+	}
+}
+
+func TestOpenFileExcl(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	const uncleanedPath = "/Classified/./" + testFileName
+	for _, path := range []string{uncleanedPath, testFileDir} {
+		_, err := m.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) {
+			t.Fatalf("%s: got: `%v', want: *os.PathError", path, err)
+		}
+		if pathErr.Op != "open" {
+			t.Errorf("%s: got: `%s', want: `open'", path, pathErr.Op)
+		}
+		if pathErr.Path != path {
+			t.Errorf("got: `%s', want: `%s'", pathErr.Path, path)
+		}
+		if !errors.Is(err, syscall.EEXIST) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.EEXIST)
+		}
+	}
+	// the existing file is left untouched
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	const newPath = "/Classified/New.txt"
+	fd, err := m.OpenFile(newPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestOpenFileTrunc(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	Time = func() time.Time { return t0 }
+	m := MockFS()
+	err := m.WriteFile("/data", []byte(testContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Time = func() time.Time { return t1 }
+	fd, err := m.OpenFile("/data", os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 0 {
+		t.Errorf("got: `%s', want: `'", bs)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Errorf("got: %d, want: 0", fi.Size())
+	}
+	if !fi.ModTime().Equal(t1) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), t1)
+	}
+}
+
+func TestOpenFileTruncAppend(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := fd.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 0 {
+		t.Errorf("got: %d, want: 0", ret)
+	}
+	const newContent = "Giraffe > Greif"
+	_, err = fd.Write([]byte(newContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != newContent {
+		t.Errorf("got: `%s', want: `%s'", bs, newContent)
+	}
+}
+
+func TestWriteFileMode(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	_, err := m.CreateAll(testFilePath, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// perm only applies when the file is created
+	err = m.WriteFile(testFilePath, []byte(testContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("got: %o, want: %o", fi.Mode().Perm(), 0600)
+	}
+
+	const newPath = "/Classified/New.txt"
+	err = m.WriteFile(newPath, []byte(testContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = m.Stat(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0644&^umask {
+		t.Errorf("got: %o, want: %o", fi.Mode().Perm(), 0644&^umask)
+	}
+}
+
+func TestOpenIndependentDescriptors(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	a, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := io.ReadAll(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	n, err := a.Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Errorf("got: `%v', want: `%v'", err, io.EOF)
+	}
+	if n != 0 {
+		t.Errorf("got: %d, want: 0", n)
+	}
+	err = a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err = io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	err = b.Close()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateNewFileTemplate(t *testing.T) {
+	const template = "// Code generated by ffs. DO NOT EDIT.\n"
+	m := MockFS(
+		WithNewFileTemplate([]byte(template)),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	const newPath = "/Classified/New.txt"
+	fd, err := m.Create(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != template {
+		t.Errorf("got: `%s', want: `%s'", bs, template)
+	}
+
+	// truncated existing files start out empty
+	_, err = m.Create(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 0 {
+		t.Errorf("got: `%s', want: `'", bs)
+	}
+}
+
+func TestFile_StatAfterRemove(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Remove(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+	if fi.Mode() != testPerm&^umask {
+		t.Errorf("got: %o, want: %o", fi.Mode(), testPerm&^umask)
+	}
+}
+
+func TestSetBusy(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	m.SetBusy(testFilePath, true)
+
+	err := m.Remove(testFilePath)
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBUSY)
+	}
+	err = m.Truncate(testFilePath, 0)
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBUSY)
+	}
+	err = m.Rename(testFilePath, "/Classified/Renamed.txt")
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBUSY)
+	}
+
+	m.SetBusy(testFilePath, false)
+
+	err = m.Remove(testFilePath)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetExecuting(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	m.SetExecuting(testFilePath, true)
+
+	_, err := m.OpenFile(testFilePath, os.O_WRONLY, 0666)
+	if !errors.Is(err, syscall.ETXTBSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ETXTBSY)
+	}
+	_, err = m.Create(testFilePath)
+	if !errors.Is(err, syscall.ETXTBSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ETXTBSY)
+	}
+	err = m.Truncate(testFilePath, 0)
+	if !errors.Is(err, syscall.ETXTBSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ETXTBSY)
+	}
+	err = m.WriteFile(testFilePath, []byte(testContent), testPerm)
+	if !errors.Is(err, syscall.ETXTBSY) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ETXTBSY)
+	}
+	// reading is still fine
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+
+	m.SetExecuting(testFilePath, false)
+
+	fd, err = m.OpenFile(testFilePath, os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+}
+
+func TestWalkDirSkipHidden(t *testing.T) {
+	m := MockFS(
+		WithFile("/.repo/.env", []byte("")),
+		WithFile("/.repo/.git/HEAD", []byte("")),
+		WithFile("/.repo/.git/objects/ab", []byte("")),
+		WithFile("/.repo/main.go", []byte("")),
+		WithFile("/.repo/src/.hidden", []byte("")),
+		WithFile("/.repo/src/visible", []byte("")),
+	)
+
+	expected, visited := []string{
+		"/.repo",
+		"/.repo/main.go",
+		"/.repo/src",
+		"/.repo/src/visible",
+	}, []string{}
+
+	err := m.WalkDirSkipHidden("/.repo", func(path string, d fs.DirEntry, err error) error {
+		visited = append(visited, path)
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expected) != len(visited) {
+		t.Fatalf("got: `%v', want: `%v'", visited, expected)
+	}
+	for i := range expected {
+		if expected[i] != visited[i] {
+			t.Errorf("got: `%s', want: `%s'", visited[i], expected[i])
+		}
+	}
+}
+
+func TestFile_Fd(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	a, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdA, fdB := a.(Fder).Fd(), b.(Fder).Fd()
+	if fdA == fdB {
+		t.Errorf("got: %d and %d, want distinct fds", fdA, fdB)
+	}
+	if fd := a.(Fder).Fd(); fd != fdA {
+		t.Errorf("got: %d, want: %d", fd, fdA)
+	}
+	if fd := b.(Fder).Fd(); fd != fdB {
+		t.Errorf("got: %d, want: %d", fd, fdB)
+	}
+}
+
+func TestWalkDirMatchesOS(t *testing.T) {
+	files := []string{
+		"/a/1.txt",
+		"/a/b/c/2.txt",
+		"/a/b/3.txt",
+		"/d/e/4.txt",
+		"/5.txt",
+	}
+	dirs := []string{
+		"/a/b/empty",
+		"/f",
+		"/d/e",
+	}
+
+	tmp := t.TempDir()
+	opts := []FSOption{}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(tmp+dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, WithDirectory("/tree"+dir))
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(tmp+file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(tmp+file, []byte(testContent), testPerm); err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, WithFile("/tree"+file, []byte(testContent)))
+	}
+	m := MockFS(opts...)
+
+	type entry struct {
+		path, name string
+		isDir      bool
+	}
+	walk := func(fsys FileSystem, root string) (entries []entry) {
+		err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if rel == "." {
+				name = "" // root names differ
+			}
+			entries = append(entries, entry{rel, name, d.IsDir()})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var real RealFileSystem
+	expected, visited := walk(&real, tmp), walk(m, "/tree")
+	if len(expected) != len(visited) {
+		t.Fatalf("got: `%v', want: `%v'", visited, expected)
+	}
+	for i := range expected {
+		if expected[i] != visited[i] {
+			t.Errorf("got: `%v', want: `%v'", visited[i], expected[i])
+		}
+	}
+}
+
+func TestMaxOpenFiles(t *testing.T) {
+	const n = 3
+	m := MockFS(
+		WithMaxOpenFiles(n),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fds := []File{}
+	for i := 0; i < n; i++ {
+		fd, err := m.Open(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds = append(fds, fd)
+	}
+	_, err := m.Open(testFilePath)
+	if !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EMFILE)
+	}
+	_, err = m.Create("/Classified/New.txt")
+	if !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EMFILE)
+	}
+
+	err = fds[0].Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.OpenFile(testFilePath, os.O_RDWR, 0666)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedundantPathComponents(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c", []byte(testContent)),
+	)
+	for _, path := range []string{"/a//b/./c", "/a/./b/../b/c", "//a/b/c"} {
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if fi.Name() != "c" {
+			t.Errorf("%s: got: `%s', want: `%s'", path, fi.Name(), "c")
+		}
+		fd, err := m.Open(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if fd.Name() != "c" {
+			t.Errorf("%s: got: `%s', want: `%s'", path, fd.Name(), "c")
+		}
+		bs, err := m.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if string(bs) != testContent {
+			t.Errorf("%s: got: `%s', want: `%s'", path, bs, testContent)
+		}
+	}
+
+	for _, path := range []string{"/a/b/", "/a//b/.", "/a/b//"} {
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if fi.Name() != "b" || !fi.IsDir() {
+			t.Errorf("%s: got: `%s' (IsDir = %t), want: directory `b'", path, fi.Name(), fi.IsDir())
+		}
+	}
+}
+
+func TestTrailingSlashOnFile(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c", []byte(testContent)),
+	)
+	_, err := m.Stat("/a/b/c/")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	_, err = m.Open("/a/b/c/")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	_, err = m.ReadFile("/a/b/c/")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	_, err = m.Create("/a/b/d/")
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EISDIR)
+	}
+	if _, ok := m.contents["/a/b/d"]; ok {
+		t.Error("file created despite trailing slash")
+	}
+}
+
+func TestMkdir(t *testing.T) {
+	m := MockFS()
+	err := m.Mkdir(testFileDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := m.contents["/Classified"]
+	if !ok {
+		t.Fatal("directory not in contents")
+	}
+	if d.parent != m.root || m.root.children["/Classified"] != d {
+		t.Error("directory not linked into its parent")
+	}
+	fi, err := m.Stat(testFileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Error("got: `IsDir = false', want: `IsDir = true'")
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Errorf("got: %o, want: %o", fi.Mode().Perm(), 0755)
+	}
+	err = m.WriteFile(testFilePath, []byte(testContent), testPerm)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMkdirErrors(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	cases := []struct {
+		path string
+		want error
+	}{
+		{"/Classified", syscall.EEXIST},
+		{testFilePath, syscall.EEXIST},
+		{"/missing/dir", syscall.ENOENT},
+		{"/missing/nested/dir", syscall.ENOENT},
+		{testFilePath + "/dir", syscall.ENOTDIR},
+	}
+	for _, c := range cases {
+		err := m.Mkdir(c.path, 0755)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got: `%v', want: `%v'", c.path, err, c.want)
+		}
+	}
+	if _, ok := m.contents["/missing"]; ok {
+		t.Error("Mkdir must not create missing parents")
+	}
+}
+
+func TestRenameDirWithOpenDescriptor(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/file.txt", []byte(testContent)),
+	)
+	fd, err := m.OpenFile("/a/b/file.txt", os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Rename("/a", "/z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := make([]byte, 4)
+	_, err = fd.Read(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent[:4] {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent[:4])
+	}
+	_, err = fd.Write([]byte("1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err = m.Open("/z/b/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testContent[:4] + "1234" + testContent[8:]
+	if string(bs) != expected {
+		t.Errorf("got: `%s', want: `%s'", bs, expected)
+	}
+	_, err = m.Open("/a/b/file.txt")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got: `%v', want: `%v'", err, os.ErrNotExist)
+	}
+}
+
+func TestMkdirAll(t *testing.T) {
+	m := MockFS(
+		WithDirectory("/a"),
+	)
+	for i := 0; i < 2; i++ {
+		err := m.MkdirAll("/a/b/c/d", 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{"/a", "/a/b", "/a/b/c", "/a/b/c/d"} {
+		d, ok := m.contents[path]
+		if !ok {
+			t.Errorf("%s: not in contents", path)
+			continue
+		}
+		if !d.isDir {
+			t.Errorf("%s: got: `isDir = false', want: `isDir = true'", path)
+		}
+		if d.parent.children[path] != d {
+			t.Errorf("%s: not linked into its parent", path)
+		}
+	}
+	if len(m.contents) != 5 {
+		t.Errorf("got: %d, want: 5 nodes", len(m.contents))
+	}
+}
+
+func TestMkdirAllErrNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	for _, path := range []string{testFilePath, testFilePath + "/a/b"} {
+		err := m.MkdirAll(path, 0755)
+		if !errors.Is(err, syscall.ENOTDIR) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.ENOTDIR)
+		}
+	}
+}
+
+func TestOpenFilePath(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, O_PATH, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+	_, err = fd.Read(make([]byte, 8))
+	if !errors.Is(err, syscall.EBADF) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBADF)
+	}
+	_, err = fd.Write([]byte(testContent))
+	if !errors.Is(err, syscall.EBADF) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBADF)
+	}
+
+	dfd, err := m.OpenFile(testFileDir, O_PATH, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = dfd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Error("expected a directory")
+	}
+}
+
+func TestRelativePaths(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c.txt", []byte(testContent)),
+	)
+	fi, err := m.Stat(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "/" {
+		t.Errorf("got: `%s', want: `%s'", fi.Name(), "/")
+	}
+
+	if err := m.Chdir("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := m.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wd != "/a/b" {
+		t.Errorf("got: `%s', want: `%s'", wd, "/a/b")
+	}
+	for path, name := range map[string]string{
+		".":     "b",
+		"..":    "a",
+		"c.txt": "c.txt",
+	} {
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if fi.Name() != name {
+			t.Errorf("%s: got: `%s', want: `%s'", path, fi.Name(), name)
+		}
+	}
+	bs, err := m.ReadFile("c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	if err := m.WriteFile("d.txt", []byte(testContent), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.contents["/a/b/d.txt"]; !ok {
+		t.Error("relative WriteFile did not create /a/b/d.txt")
+	}
+
+	// relative to the new working directory
+	if err := m.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("b/c.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChdirErrors(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/c.txt", []byte(testContent)),
+	)
+	err := m.Chdir("/a/b/c.txt")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	err = m.Chdir("/x")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	wd, _ := m.Getwd()
+	if wd != "/" {
+		t.Errorf("got: `%s', want: `%s'", wd, "/")
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/zeta.txt", []byte(testContent)),
+		WithFile("/a/alpha.txt", nil),
+		WithDirectory("/a/mid"),
+		WithFile("/a/beta/inner.txt", nil),
+	)
+	entries, err := m.ReadDir("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name  string
+		isDir bool
+	}{
+		{"alpha.txt", false},
+		{"beta", true},
+		{"mid", true},
+		{"zeta.txt", false},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want: %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		if e.Name() != want[i].name || e.IsDir() != want[i].isDir {
+			t.Errorf("entry %d: got: `%s' (IsDir = %t), want: `%s' (IsDir = %t)", i, e.Name(), e.IsDir(), want[i].name, want[i].isDir)
+		}
+	}
+	fi, err := entries[3].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got size: %d, want: %d", fi.Size(), len(testContent))
+	}
+
+	_, err = m.ReadDir("/a/zeta.txt")
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	_, err = m.ReadDir("/b")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestReadDirFileBatches(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/e", nil),
+		WithFile("/a/c", nil),
+		WithFile("/a/a", nil),
+		WithDirectory("/a/d"),
+		WithFile("/a/b", nil),
+	)
+	fd, err := m.Open("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	d, ok := fd.(fs.ReadDirFile)
+	if !ok {
+		t.Fatal("directory descriptor doesn't implement fs.ReadDirFile")
+	}
+	var names []string
+	for _, want := range []int{2, 2, 1} {
+		entries, err := d.ReadDir(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != want {
+			t.Fatalf("got %d entries, want: %d", len(entries), want)
+		}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+	}
+	if !slices.Equal(names, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("got: `%v', want: `[a b c d e]'", names)
+	}
+	entries, err := d.ReadDir(2)
+	if err != io.EOF || len(entries) != 0 {
+		t.Errorf("got: `%v' (%v), want: `%v'", entries, err, io.EOF)
+	}
+	// reading all remaining entries at the end isn't an error
+	entries, err = d.ReadDir(-1)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("got: `%v' (%v), want: no entries", entries, err)
+	}
+
+	// seeking to the start reads the directory again
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = d.ReadDir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("got %d entries, want: 5", len(entries))
+	}
+}
+
+func TestReadDirFileNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	_, err = fd.(fs.ReadDirFile).ReadDir(-1)
+	if !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+}
+
+func TestOpenFileIgnoredFlags(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR|syscall.O_CLOEXEC|os.O_CREATE, testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err = m.OpenFile(testFilePath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	bs, err = io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestCreateErrNotExistMatchesOS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("compares against linux behaviour")
+	}
+	dir := t.TempDir()
+	m := MockFS(
+		WithDirectory(dir),
+	)
+	var real RealFileSystem
+	for _, path := range []string{dir + "/missing/file.txt", dir + "//missing/./file.txt"} {
+		_, realErr := real.Create(path)
+		_, err := m.Create(path)
+		comparePathErrors(t, "Create "+path, err, realErr)
+
+		realErr = real.WriteFile(path, []byte(testContent), testPerm)
+		err = m.WriteFile(path, []byte(testContent), testPerm)
+		comparePathErrors(t, "WriteFile "+path, err, realErr)
+	}
+}
+
+// comparePathErrors fails unless err is an *os.PathError equal to want.
+func comparePathErrors(t *testing.T, name string, err, want error) {
+	t.Helper()
+	var wantPathErr *os.PathError
+	if !errors.As(want, &wantPathErr) {
+		t.Fatalf("%s: got: `%v', want: *os.PathError", name, want)
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("%s: got: `%v', want: *os.PathError", name, err)
+	}
+	if *pathErr != *wantPathErr {
+		t.Errorf("%s: got: `%#v', want: `%#v'", name, *pathErr, *wantPathErr)
+	}
+}
+
+func TestFile_WriteAtReadAt(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte("0123456789")),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	steps := []struct {
+		writeAt int64
+		data    string
+		want    string
+	}{
+		{2, "ab", "01ab456789"},
+		{8, "xyz", "01ab4567xyz"},
+		{0, "A", "A1ab4567xyz"},
+		{13, "!", "A1ab4567xyz\x00\x00!"},
+	}
+	for _, step := range steps {
+		n, err := fd.WriteAt([]byte(step.data), step.writeAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(step.data) {
+			t.Errorf("got: %d, want: %d", n, len(step.data))
+		}
+		bs := make([]byte, len(step.want))
+		n, err = fd.ReadAt(bs, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs[:n]) != step.want {
+			t.Errorf("got: %q, want: %q", bs[:n], step.want)
+		}
+		// reading past the end returns what's there, along with io.EOF
+		bs = make([]byte, 4)
+		n, err = fd.ReadAt(bs, int64(len(step.want)-2))
+		if err != io.EOF {
+			t.Errorf("got: `%v', want: `%v'", err, io.EOF)
+		}
+		if string(bs[:n]) != step.want[len(step.want)-2:] {
+			t.Errorf("got: %q, want: %q", bs[:n], step.want[len(step.want)-2:])
+		}
+	}
+
+	// the cursor is unaffected
+	bs := make([]byte, 3)
+	if _, err := fd.Read(bs); err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "A1a" {
+		t.Errorf("got: %q, want: %q", bs, "A1a")
+	}
+
+	if _, err := fd.WriteAt([]byte("x"), -1); err == nil {
+		t.Error("expected an error writing at a negative offset")
+	}
+	afd, err := m.OpenFile(testFilePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer afd.Close()
+	if _, err := afd.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("expected an error using WriteAt with O_APPEND")
+	}
+}
+
+func TestDirectoryModTime(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	Time = func() time.Time { return now }
+	m := MockFS(
+		WithDirectory("/a"),
+		WithDirectory("/b"),
+	)
+
+	// every change to its entries updates the directory's mtime
+	steps := []struct {
+		name string
+		dirs []string
+		op   func() error
+	}{
+		{"WriteFile", []string{"/a"}, func() error { return m.WriteFile("/a/f", []byte(testContent), testPerm) }},
+		{"Mkdir", []string{"/a"}, func() error { return m.Mkdir("/a/d", 0755) }},
+		{"Symlink", []string{"/a"}, func() error { return m.Symlink("f", "/a/l") }},
+		{"Rename", []string{"/a", "/b"}, func() error { return m.Rename("/a/f", "/b/f") }},
+		{"Remove", []string{"/b"}, func() error { return m.Remove("/b/f") }},
+		{"RemoveAll", []string{"/a"}, func() error { return m.RemoveAll("/a/d") }},
+	}
+	for _, step := range steps {
+		now = now.Add(time.Hour)
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		for _, dir := range step.dirs {
+			fi, err := m.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.ModTime().Equal(now) {
+				t.Errorf("%s: %s: got: `%v', want: `%v'", step.name, dir, fi.ModTime(), now)
+			}
+		}
+	}
+}
+
+func TestRemoveFreesUsage(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/other", []byte(testContent)),
+	)
+	if usage := m.Usage(); usage != 2*int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", usage, 2*len(testContent))
+	}
+	if err := m.Remove(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if usage := m.Usage(); usage != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", usage, len(testContent))
+	}
+}
+
+func TestFile_ReadWriteOnly(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	_, err = fd.Read(make([]byte, 4))
+	if !errors.Is(err, syscall.EBADF) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBADF)
+	}
+	_, err = fd.ReadAt(make([]byte, 4), 0)
+	if !errors.Is(err, syscall.EBADF) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EBADF)
+	}
+
+	dfd, err := m.Open(testFileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dfd.Close()
+	_, err = dfd.Read(make([]byte, 4))
+	if !errors.Is(err, syscall.EISDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EISDIR)
+	}
+}
+
+func TestFile_SeekNegative(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if _, err := fd.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		offset int64
+		whence int
+	}{
+		{-1, io.SeekStart},
+		{-5, io.SeekCurrent},
+		{-int64(len(testContent)) - 1, io.SeekEnd},
+	}
+	for _, c := range cases {
+		_, err := fd.Seek(c.offset, c.whence)
+		if !errors.Is(err, syscall.EINVAL) {
+			t.Errorf("offset: %d, whence: %d: got: `%v', want: `%v'", c.offset, c.whence, err, syscall.EINVAL)
+		}
+		// the cursor is unchanged
+		pos, err := fd.Seek(0, io.SeekCurrent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != 4 {
+			t.Errorf("offset: %d, whence: %d: got: %d, want: 4", c.offset, c.whence, pos)
+		}
+	}
+	// seeking to the very beginning from the end is fine
+	pos, err := fd.Seek(-int64(len(testContent)), io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 0 {
+		t.Errorf("got: %d, want: 0", pos)
+	}
+}
+
+func TestCreateModeMasking(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	for _, perm := range []fs.FileMode{0600, 0640, 0755, 0000} {
+		path := fmt.Sprintf("/Classified/%o", perm)
+		if err := m.WriteFile(path, []byte(testContent), perm); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// permission bits outside of the umask are kept as they are, which
+		// subtraction would get wrong for 0600 - 0022
+		if want := perm &^ umask; fi.Mode() != want {
+			t.Errorf("%o: got: %o, want: %o", perm, fi.Mode(), want)
+		}
+	}
+	fi, err := m.Stat(testFileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fs.ModeDir | 0755; fi.Mode() != want {
+		t.Errorf("got: %v, want: %v", fi.Mode(), want)
+	}
+}
+
+func TestChmod(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 {
+		t.Errorf("got: %v, want: %v", fi.Mode(), fs.FileMode(0600))
+	}
+
+	// the type is kept
+	if err := m.Chmod(testFileDir, 0700|fs.ModeSymlink); err != nil {
+		t.Fatal(err)
+	}
+	fi, err = m.Stat(testFileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fs.ModeDir | 0700; fi.Mode() != want {
+		t.Errorf("got: %v, want: %v", fi.Mode(), want)
+	}
+
+	err = m.Chmod("/missing", 0600)
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestChtimes(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	atime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	mtime := time.Date(2002, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := m.Chtimes(testFilePath, atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), mtime)
+	}
+
+	// a zero time is left unchanged
+	if err := m.Chtimes(testFilePath, atime, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err = m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), mtime)
+	}
+
+	err = m.Chtimes("/missing", atime, mtime)
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestAccessTime(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	Time = func() time.Time { return now }
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	atime := func() time.Time {
+		fd, err := m.OpenFile(testFilePath, O_PATH, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fd.Close()
+		return fd.(*FakeFileDescriptor).AccessTime()
+	}
+	created := now
+
+	// stats, including those of a walk, don't count as access
+	now = now.Add(time.Hour)
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	err := m.WalkDir("/", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		_, err = d.Info()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atime(); !got.Equal(created) {
+		t.Errorf("got: `%v', want: `%v'", got, created)
+	}
+
+	reads := []struct {
+		name string
+		read func() error
+	}{
+		{"ReadFile", func() error {
+			_, err := m.ReadFile(testFilePath)
+			return err
+		}},
+		{"Read", func() error {
+			fd, err := m.Open(testFilePath)
+			if err != nil {
+				return err
+			}
+			defer fd.Close()
+			_, err = fd.Read(make([]byte, 4))
+			return err
+		}},
+		{"ReadAt", func() error {
+			fd, err := m.Open(testFilePath)
+			if err != nil {
+				return err
+			}
+			defer fd.Close()
+			_, err = fd.ReadAt(make([]byte, 4), 2)
+			return err
+		}},
+	}
+	for _, r := range reads {
+		now = now.Add(time.Hour)
+		if err := r.read(); err != nil {
+			t.Fatalf("%s: %v", r.name, err)
+		}
+		if got := atime(); !got.Equal(now) {
+			t.Errorf("%s: got: `%v', want: `%v'", r.name, got, now)
+		}
+	}
+}
+
+func TestNoAtime(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	for _, noAtime := range []bool{false, true} {
+		Time = func() time.Time { return t0 }
+		opts := []FSOption{WithFile(testFilePath, []byte(testContent))}
+		if noAtime {
+			opts = append(opts, WithNoAtime())
+		}
+		m := MockFS(opts...)
+
+		Time = func() time.Time { return t1 }
+		if _, err := m.ReadFile(testFilePath); err != nil {
+			t.Fatal(err)
+		}
+		fd, err := m.Open(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(fd); err != nil {
+			t.Fatal(err)
+		}
+		want := t1
+		if noAtime {
+			want = t0
+		}
+		if got := fd.(*FakeFileDescriptor).AccessTime(); !got.Equal(want) {
+			t.Errorf("noatime: %t: got: `%v', want: `%v'", noAtime, got, want)
+		}
+		fd.Close()
+	}
+}
+
+func TestFile_Sync(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ffd := fd.(*FakeFileDescriptor)
+	if ffd.Synced() {
+		t.Error("expected the descriptor not to be synced yet")
+	}
+	if err := fd.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if !ffd.Synced() {
+		t.Error("expected the descriptor to be synced")
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err = fd.Sync()
+	if !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got: `%v', want: `%v'", err, os.ErrClosed)
+	}
+}
@@ -0,0 +1,99 @@
+package ffs
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob returns the paths of all files matching pattern, in lexicographical
+// order, like filepath.Glob: the syntax is that of filepath.Match, applied
+// per path component, so "*" never matches a separator (nor does "**").
+// A relative pattern is matched against the working directory, and results
+// in paths relative to it.
+// The only possible error is filepath.ErrBadPattern.
+func (m *FakeFileSystem) Glob(pattern string) (matches []string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// check for a bad pattern up front, so that the result does not depend
+	// on which files exist
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	rel := !filepath.IsAbs(pattern)
+	pat := strings.Split(m.abs(pattern), "/")
+	for path := range m.contents {
+		if path == "/" {
+			continue
+		}
+		comps := strings.Split(path, "/")
+		if len(comps) != len(pat) {
+			continue
+		}
+		matched := true
+		for i := range pat {
+			if ok, _ := filepath.Match(pat[i], comps[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if rel {
+			path, _ = filepath.Rel(m.cwd, path)
+		}
+		matches = append(matches, path)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// GlobStar returns the paths of all files matching pattern, in lexicographical
+// order.
+// In addition to the syntax of filepath.Match, which is applied per path
+// component, a component consisting of "**" matches zero or more components,
+// e.g. /src/**/*.go matches .go files at any depth below /src.
+func (m *FakeFileSystem) GlobStar(pattern string) (matches []string, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pat := strings.Split(pattern, "/")
+	for _, p := range pat {
+		if p == "**" {
+			continue
+		}
+		// check for bad patterns up front, so that the result does not
+		// depend on which files exist
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+	for path := range m.contents {
+		if path == "/" {
+			continue
+		}
+		if matchComponents(pat, strings.Split(path, "/")) {
+			matches = append(matches, path)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchComponents matches the path components against the pattern
+// components, where "**" matches any number of path components.
+func matchComponents(pat, path []string) bool {
+	if len(pat) == 0 {
+		return len(path) == 0
+	}
+	if pat[0] == "**" {
+		return matchComponents(pat[1:], path) || (len(path) > 0 && matchComponents(pat, path[1:]))
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pat[0], path[0]); !ok {
+		return false
+	}
+	return matchComponents(pat[1:], path[1:])
+}
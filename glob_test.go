@@ -0,0 +1,126 @@
+package ffs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestGlobStar(t *testing.T) {
+	m := MockFS(
+		WithFile("/src/main.go", []byte("")),
+		WithFile("/src/README.md", []byte("")),
+		WithFile("/src/fs/fs.go", []byte("")),
+		WithFile("/src/fs/internal/path.go", []byte("")),
+		WithFile("/test/fs_test.go", []byte("")),
+	)
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"**/*.go", []string{
+			"/src/fs/fs.go",
+			"/src/fs/internal/path.go",
+			"/src/main.go",
+			"/test/fs_test.go",
+		}},
+		{"/src/**/*.go", []string{
+			"/src/fs/fs.go",
+			"/src/fs/internal/path.go",
+			"/src/main.go",
+		}},
+		{"/src/fs/**", []string{
+			"/src/fs",
+			"/src/fs/fs.go",
+			"/src/fs/internal",
+			"/src/fs/internal/path.go",
+		}},
+		{"/src/*.md", []string{
+			"/src/README.md",
+		}},
+	}
+	for _, c := range cases {
+		matches, err := m.GlobStar(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != len(c.expected) {
+			t.Errorf("%s: got: `%v', want: `%v'", c.pattern, matches, c.expected)
+			continue
+		}
+		for i := range c.expected {
+			if matches[i] != c.expected[i] {
+				t.Errorf("%s: got: `%s', want: `%s'", c.pattern, matches[i], c.expected[i])
+			}
+		}
+	}
+}
+
+func TestGlobStarBadPattern(t *testing.T) {
+	m := MockFS()
+	_, err := m.GlobStar("/src/**/[")
+	if err == nil {
+		t.Error("expected bad pattern to report error")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	m := MockFS(
+		WithFile("/etc/fstab", []byte("")),
+		WithFile("/etc/host.conf", []byte("")),
+		WithFile("/etc/resolv.conf", []byte("")),
+		WithFile("/etc/conf.d/net.conf", []byte("")),
+		WithFile("/var/log/a1", []byte("")),
+		WithFile("/var/log/a2", []byte("")),
+		WithFile("/var/log/b1", []byte("")),
+		WithFile("/var/log/a10", []byte("")),
+	)
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"/etc/*.conf", []string{"/etc/host.conf", "/etc/resolv.conf"}},
+		{"/etc/*", []string{"/etc/conf.d", "/etc/fstab", "/etc/host.conf", "/etc/resolv.conf"}},
+		{"/var/log/a?", []string{"/var/log/a1", "/var/log/a2"}},
+		{"/var/log/[ab]1", []string{"/var/log/a1", "/var/log/b1"}},
+		{"/var/log/[^a]*", []string{"/var/log/b1"}},
+		{"/var/**", []string{"/var/log"}}, // no special meaning
+		{"/etc/fstab", []string{"/etc/fstab"}},
+		{"/etc/missing", nil},
+	}
+	for _, c := range cases {
+		matches, err := m.Glob(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(matches, c.expected) {
+			t.Errorf("%s: got: `%v', want: `%v'", c.pattern, matches, c.expected)
+		}
+	}
+}
+
+func TestGlobRelative(t *testing.T) {
+	m := MockFS(
+		WithFile("/etc/host.conf", []byte("")),
+		WithFile("/etc/resolv.conf", []byte("")),
+	)
+	if err := m.Chdir("/etc"); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := m.Glob("*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"host.conf", "resolv.conf"}
+	if !slices.Equal(matches, expected) {
+		t.Errorf("got: `%v', want: `%v'", matches, expected)
+	}
+}
+
+func TestGlobBadPattern(t *testing.T) {
+	m := MockFS()
+	if _, err := m.Glob("/src/["); err != filepath.ErrBadPattern {
+		t.Errorf("got: `%v', want: `%v'", err, filepath.ErrBadPattern)
+	}
+}
@@ -0,0 +1,105 @@
+package ffs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipMagic are the first two bytes of every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// WithTransparentGzip stores files compressed, while presenting their
+// plaintext to the user.
+// A file is considered compressed if its name ends in suffix, or its stored
+// content starts with the gzip magic number. An empty suffix only does the
+// latter.
+// ReadFile, Stat, and descriptors returned by Open, OpenFile, and Create see
+// the decompressed bytes. WriteFile compresses the data before storing it.
+// Writes through a descriptor are compressed and stored once it's synced or
+// closed; until then, they're only visible through that descriptor.
+func WithTransparentGzip(suffix string) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.transparentGzip = true
+		fs.gzipSuffix = suffix
+	}
+}
+
+func (m *FakeFileSystem) isGzipped(f *FakeFile) bool {
+	if !m.transparentGzip {
+		return false
+	}
+	if m.gzipSuffix != "" && strings.HasSuffix(f.name, m.gzipSuffix) {
+		return true
+	}
+	return bytes.HasPrefix(f.bytes, gzipMagic)
+}
+
+// decompress makes fd read and write the plaintext of its file, if it's
+// compressed.
+func (m *FakeFileSystem) decompress(fd *FakeFileDescriptor, path string) error {
+	if fd.file.isDir || (fd.flag&O_PATH) != 0 || !m.isGzipped(fd.file) {
+		return nil
+	}
+	bs, err := gunzip(fd.file.bytes)
+	if err != nil {
+		return &os.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  err,
+		}
+	}
+	fd.gzipped = true
+	fd.decompressed = bs
+	return nil
+}
+
+// compress stores the plaintext written through fd compressed.
+func (fd *FakeFileDescriptor) compress() {
+	if fd.gzipped && fd.dirty {
+		fd.file.bytes = gzipBytes(fd.decompressed)
+		fd.file.gen++
+		fd.dirty = false
+	}
+}
+
+// plainSize is the size of f as presented to the user, that is, the size of
+// its plaintext if it's compressed.
+func (m *FakeFileSystem) plainSize(f *FakeFile) int64 {
+	if !f.isDir && !f.symlink && m.isGzipped(f) {
+		if bs, err := gunzip(f.bytes); err == nil {
+			return int64(len(bs))
+		}
+	}
+	return f.size()
+}
+
+// snapshot is f.snapshot, with the size of the plaintext if f is compressed.
+func (m *FakeFileSystem) snapshot(f *FakeFile) *fakeFileInfo {
+	fi := f.snapshot()
+	fi.size = m.plainSize(f)
+	return fi
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		// an empty file is the compressed form of empty content
+		return []byte{}, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data) // writes to a bytes.Buffer don't fail
+	w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,189 @@
+package ffs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestTransparentGzipRead(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(".gz"),
+		WithFile("/fixtures/data.txt.gz", gzipBytes([]byte(testContent))),
+	)
+	bs, err := m.ReadFile("/fixtures/data.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	fd, err := m.Open("/fixtures/data.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = make([]byte, 128)
+	n, err := fd.Read(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = bs[:n]
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestTransparentGzipDetectsMagic(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(""),
+		WithFile(testFilePath, gzipBytes([]byte(testContent))),
+	)
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestTransparentGzipWrite(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(".gz"),
+		WithDirectory("/fixtures"),
+	)
+	err := m.WriteFile("/fixtures/data.txt.gz", []byte(testContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := m.contents["/fixtures/data.txt.gz"].bytes
+	if !bytes.HasPrefix(stored, gzipMagic) {
+		t.Fatalf("got: `%x', want gzip compressed content", stored)
+	}
+	plain, err := gunzip(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != testContent {
+		t.Errorf("got: `%s', want: `%s'", plain, testContent)
+	}
+	bs, err := m.ReadFile("/fixtures/data.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestTransparentGzipOpenFile(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(".gz"),
+		WithFile("/fixtures/data.txt.gz", gzipBytes([]byte(testContent))),
+	)
+	fi, err := m.Stat("/fixtures/data.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got size: %d, want: %d", fi.Size(), len(testContent))
+	}
+
+	fd, err := m.OpenFile("/fixtures/data.txt.gz", os.O_RDWR, testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	fi, err = fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got size: %d, want: %d", fi.Size(), len(testContent))
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransparentGzipDescriptorWrite(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(".gz"),
+		WithFile("/fixtures/data.txt.gz", gzipBytes([]byte(testContent))),
+	)
+	fd, err := m.OpenFile("/fixtures/data.txt.gz", os.O_WRONLY|os.O_APPEND, testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const more = "\nGiraffe > Greif"
+	if _, err := fd.Write([]byte(more)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stored := m.contents["/fixtures/data.txt.gz"].bytes
+	if !bytes.HasPrefix(stored, gzipMagic) {
+		t.Fatalf("got: `%x', want gzip compressed content", stored)
+	}
+	bs, err := m.ReadFile("/fixtures/data.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent+more {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent+more)
+	}
+
+	// a new file is compressed as well
+	fd, err = m.Create("/fixtures/new.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte(testContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stored = m.contents["/fixtures/new.txt.gz"].bytes
+	if !bytes.HasPrefix(stored, gzipMagic) {
+		t.Fatalf("got: `%x', want gzip compressed content", stored)
+	}
+	bs, err = m.ReadFile("/fixtures/new.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestTransparentGzipEmpty(t *testing.T) {
+	m := MockFS(
+		WithTransparentGzip(".gz"),
+		WithFile("/fixtures/empty.gz", nil),
+	)
+	bs, err := m.ReadFile("/fixtures/empty.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bs) != 0 {
+		t.Errorf("got: `%s', want empty content", bs)
+	}
+	fd, err := m.Open("/fixtures/empty.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if _, err := fd.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("got: %v, want: %v", err, io.EOF)
+	}
+}
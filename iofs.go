@@ -0,0 +1,101 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// ioFS adapts a FakeFileSystem to the io/fs interfaces, see AsFS.
+type ioFS struct {
+	m *FakeFileSystem
+}
+
+var _ fs.FS = ioFS{}
+var _ fs.StatFS = ioFS{}
+var _ fs.ReadDirFS = ioFS{}
+var _ fs.ReadFileFS = ioFS{}
+
+// AsFS returns the file system as an fs.FS, so it can be passed to functions
+// such as fs.WalkDir, fs.Glob, or template.ParseFS.
+// Names follow the io/fs conventions (see fs.ValidPath): they are unrooted
+// and slash-separated, and "." names the root directory /.
+func (m *FakeFileSystem) AsFS() fs.FS {
+	return ioFS{m}
+}
+
+// path translates the fs.FS name to an absolute path.
+func (f ioFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	if name == "." {
+		return "/", nil
+	}
+	return "/" + name, nil
+}
+
+// relabel makes an error returned for the absolute path refer to name
+// instead.
+func relabel(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{
+			Op:   pe.Op,
+			Path: name,
+			Err:  pe.Err,
+		}
+	}
+	return err
+}
+
+func (f ioFS) Open(name string) (fs.File, error) {
+	path, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := f.m.Open(path)
+	if err != nil {
+		return nil, relabel(err, name)
+	}
+	return fd, nil
+}
+
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.m.Stat(path)
+	if err != nil {
+		return nil, relabel(err, name)
+	}
+	return fi, nil
+}
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.m.ReadDir(path)
+	if err != nil {
+		return nil, relabel(err, name)
+	}
+	return entries, nil
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error) {
+	path, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := f.m.ReadFile(path)
+	if err != nil {
+		return nil, relabel(err, name)
+	}
+	return bs, nil
+}
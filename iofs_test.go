@@ -0,0 +1,85 @@
+package ffs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestAsFSWalkDir(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b.txt", []byte(testContent)),
+		WithFile("/a/c/d.txt", nil),
+		WithDirectory("/e"),
+	)
+	var paths []string
+	err := fs.WalkDir(m.AsFS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a", "a/b.txt", "a/c", "a/c/d.txt", "e"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("got: %v, want: %v", paths, want)
+	}
+}
+
+func TestAsFSOpen(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b.txt", []byte(testContent)),
+	)
+	fsys := m.AsFS()
+	fd, err := fsys.Open("a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	_, err = fsys.Open("a/missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got: `%v', want: `%v'", err, fs.ErrNotExist)
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) && pe.Path != "a/missing.txt" {
+		t.Errorf("got path: `%s', want: `%s'", pe.Path, "a/missing.txt")
+	}
+	for _, name := range []string{"/a/b.txt", "a/../a/b.txt", "a/b.txt/", ""} {
+		_, err = fsys.Open(name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: got: `%v', want: `%v'", name, err, fs.ErrInvalid)
+		}
+	}
+}
+
+func TestAsFSTemplate(t *testing.T) {
+	m := MockFS(
+		WithFile("/templates/hello.tmpl", []byte(`Hello, {{.}}!`)),
+		WithFile("/templates/bye.tmpl", []byte(`Bye, {{.}}!`)),
+	)
+	tmpl, err := template.ParseFS(m.AsFS(), "templates/*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := tmpl.ExecuteTemplate(&sb, "hello.tmpl", "Greif"); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "Hello, Greif!" {
+		t.Errorf("got: `%s', want: `%s'", sb.String(), "Hello, Greif!")
+	}
+}
@@ -0,0 +1,137 @@
+package ffs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonFile is the JSON representation of a node of the tree, see
+// FakeFileSystem.MarshalJSON.
+type jsonFile struct {
+	Name     string      `json:"name"`
+	Mode     fs.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"modtime"`
+	Contents []byte      `json:"contents,omitempty"` // base64 encoded
+	Target   string      `json:"target,omitempty"`   // symlinks only
+	Children []*jsonFile `json:"children,omitempty"` // directories only
+}
+
+var (
+	_ json.Marshaler   = (*FakeFileSystem)(nil)
+	_ json.Unmarshaler = (*FakeFileSystem)(nil)
+)
+
+// MarshalJSON serializes the tree as nested objects, starting at the root
+// directory "/". Each object has the name, mode (including the type bits),
+// and modification time of the file, and, depending on its type, the base64
+// encoded contents, the symlink target, or the directory's children, sorted
+// by name. Hard links are not preserved, every name is serialized as a file
+// of its own.
+func (m *FakeFileSystem) MarshalJSON() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return json.Marshal(toJSON(m.root))
+}
+
+func toJSON(f *FakeFile) *jsonFile {
+	j := &jsonFile{
+		Name:    f.name,
+		Mode:    f.fileMode(),
+		ModTime: f.lastMod,
+	}
+	switch {
+	case f.isDir:
+		for _, c := range f.children {
+			j.Children = append(j.Children, toJSON(c))
+		}
+		sort.Slice(j.Children, func(i, k int) bool {
+			return j.Children[i].Name < j.Children[k].Name
+		})
+	case f.symlink:
+		j.Target = f.linkTarget
+	default:
+		j.Contents = f.bytes
+	}
+	return j
+}
+
+// UnmarshalJSON replaces the tree with the one serialized by MarshalJSON.
+// The options of the file system are kept. Unmarshaling into a zero
+// FakeFileSystem results in one like MockFS would create.
+func (m *FakeFileSystem) UnmarshalJSON(data []byte) error {
+	var j jsonFile
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Name != "/" || !j.Mode.IsDir() {
+		return fmt.Errorf("ffs: root must be a directory named \"/\", got %q (%v)", j.Name, j.Mode)
+	}
+	contents := map[string]*FakeFile{}
+	root, err := fromJSON(&j, "/", nil, contents)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.contents {
+		m.unlink(f)
+	}
+	m.root, m.parent = root, root
+	m.contents = contents
+	if m.cwd == "" {
+		m.cwd = "/"
+	}
+	if _, ok := contents[m.cwd]; !ok {
+		m.cwd = "/"
+	}
+	if m.readCache != nil {
+		m.readCache = map[string]readCacheEntry{}
+	}
+	return nil
+}
+
+func fromJSON(j *jsonFile, path string, parent *FakeFile, contents map[string]*FakeFile) (*FakeFile, error) {
+	f := &FakeFile{
+		inode: &inode{
+			mode:       j.Mode &^ fs.ModeDir,
+			lastMod:    j.ModTime,
+			lastChange: j.ModTime,
+			lastAccess: j.ModTime,
+			nlink:      1,
+		},
+		path:   path,
+		name:   j.Name,
+		parent: parent,
+	}
+	switch {
+	case j.Mode.IsDir():
+		f.isDir = true
+		f.children = map[string]*FakeFile{}
+		for _, cj := range j.Children {
+			if cj.Name == "" || cj.Name == "." || cj.Name == ".." || strings.Contains(cj.Name, "/") {
+				return nil, fmt.Errorf("ffs: invalid file name %q in %s", cj.Name, path)
+			}
+			cpath := filepath.Join(path, cj.Name)
+			if _, ok := f.children[cpath]; ok {
+				return nil, fmt.Errorf("ffs: duplicate file %s", cpath)
+			}
+			c, err := fromJSON(cj, cpath, f, contents)
+			if err != nil {
+				return nil, err
+			}
+			f.children[cpath] = c
+		}
+	case j.Mode.Type() == fs.ModeSymlink:
+		f.symlink = true
+		f.linkTarget = j.Target
+	default:
+		f.bytes = j.Contents
+	}
+	contents[path] = f
+	return f, nil
+}
@@ -0,0 +1,87 @@
+package ffs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/home/empty", nil),
+		WithDirectory("/var/empty"),
+	)
+	if err := m.Symlink(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var u FakeFileSystem
+	if err := json.Unmarshal(data, &u); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.String(), m.String(); got != want {
+		t.Errorf("got: `%s', want: `%s'", got, want)
+	}
+	if len(u.contents) != len(m.contents) {
+		t.Errorf("got: %d files, want: %d", len(u.contents), len(m.contents))
+	}
+	for path, want := range m.contents {
+		got, ok := u.contents[path]
+		if !ok {
+			t.Errorf("%s: missing", path)
+			continue
+		}
+		if got.fileMode() != want.fileMode() {
+			t.Errorf("%s: got: `%v', want: `%v'", path, got.fileMode(), want.fileMode())
+		}
+		if !got.lastMod.Equal(want.lastMod) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, got.lastMod, want.lastMod)
+		}
+		if got.parent != nil && u.contents[got.parent.path] != got.parent {
+			t.Errorf("%s: parent is not part of the tree", path)
+		}
+	}
+	bs, err := u.ReadFile("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	// serializing again gives the same result
+	again, err := json.Marshal(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("got: `%s', want: `%s'", again, data)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"name":"home","mode":2147484141}`,
+		`{"name":"/","mode":420}`,
+		`{"name":"/","mode":2147484141,"children":[{"name":"a/b","mode":420}]}`,
+		`{"name":"/","mode":2147484141,"children":[{"name":"a","mode":420},{"name":"a","mode":420}]}`,
+	} {
+		m := MockFS(
+			WithFile(testFilePath, []byte(testContent)),
+		)
+		if err := json.Unmarshal([]byte(data), m); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+		// the tree is left as it was
+		if _, err := m.Stat(testFilePath); err != nil {
+			t.Error(err)
+		}
+	}
+}
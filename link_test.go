@@ -0,0 +1,141 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestLink(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Link(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	// writing through one name is visible through the other
+	if err := m.WriteFile("/link", []byte("changed"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "changed" {
+		t.Errorf("got: `%s', want: `changed'", bs)
+	}
+	if err := m.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 {
+		t.Errorf("got: `%v', want: `%v'", fi.Mode(), os.FileMode(0600))
+	}
+	// the space is only taken once
+	if u := m.Usage(); u != int64(len("changed")) {
+		t.Errorf("got: %d, want: %d", u, len("changed"))
+	}
+}
+
+func TestLinkRemove(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Link(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	if u := m.Usage(); u != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", u, len(testContent))
+	}
+	if err := m.Remove("/link"); err != nil {
+		t.Fatal(err)
+	}
+	if u := m.Usage(); u != 0 {
+		t.Errorf("got: %d, want: 0", u)
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/other", nil),
+	)
+	cases := []struct {
+		oldname, newname string
+		err              error
+	}{
+		{testFileDir, "/dir", syscall.EPERM},
+		{testFilePath, "/other", syscall.EEXIST},
+		{"/missing", "/link", syscall.ENOENT},
+		{testFilePath, "/missing/link", syscall.ENOENT},
+	}
+	for _, c := range cases {
+		err := m.Link(c.oldname, c.newname)
+		var linkErr *os.LinkError
+		if !errors.As(err, &linkErr) || linkErr.Op != "link" {
+			t.Errorf("%s -> %s: got: `%v', want: *os.LinkError", c.oldname, c.newname, err)
+		}
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s -> %s: got: `%v', want: `%v'", c.oldname, c.newname, err, c.err)
+		}
+	}
+}
+
+func TestRenameOntoLink(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Link(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	// both names refer to the same file, rename(2) does nothing
+	if err := m.Rename(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{testFilePath, "/link"} {
+		if _, err := m.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCloneKeepsLinks(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Link(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	c := m.Clone()
+	if err := c.WriteFile("/link", []byte("changed"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := c.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "changed" {
+		t.Errorf("got: `%s', want: `changed'", bs)
+	}
+	bs, err = m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
@@ -0,0 +1,92 @@
+package ffs
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Flock applies or removes an advisory lock on the file, like flock(2).
+// how is one of LOCK_SH, LOCK_EX or LOCK_UN, optionally or'ed with LOCK_NB
+// (as defined by package syscall on Unix systems).
+// The lock belongs to the file, not to its path: it's released when the
+// descriptor is closed, and a file later created at the same path after
+// the original was removed starts out unlocked.
+// A conflicting lock blocks until it's released, or fails with EWOULDBLOCK
+// if LOCK_NB is set (or the descriptor was not opened through a file
+// system, with which it could wait).
+func (m *FakeFileDescriptor) Flock(how int) error {
+	m.lock()
+	defer m.unlock()
+	if m.closed {
+		return &os.PathError{
+			Op:   "flock",
+			Path: m.file.path,
+			Err:  syscall.EBADF,
+		}
+	}
+	nonBlocking := how&lockNB != 0
+	switch how &^ lockNB {
+	case lockUN:
+		m.unlockFile()
+		return nil
+	case lockSH, lockEX:
+	default:
+		return &os.PathError{
+			Op:   "flock",
+			Path: m.file.path,
+			Err:  syscall.EINVAL,
+		}
+	}
+	kind := how &^ lockNB
+	for m.file.lockConflicts(m, kind) {
+		if nonBlocking || m.fsys == nil {
+			return &os.PathError{
+				Op:   "flock",
+				Path: m.file.path,
+				Err:  syscall.EWOULDBLOCK,
+			}
+		}
+		m.fsys.lockCond().Wait()
+	}
+	if m.file.locks == nil {
+		m.file.locks = map[*FakeFileDescriptor]int{}
+	}
+	m.file.locks[m] = kind
+	return nil
+}
+
+// lockConflicts reports whether a lock of kind held by fd would conflict
+// with the locks held by other descriptors on f.
+func (f *FakeFile) lockConflicts(fd *FakeFileDescriptor, kind int) bool {
+	for other, held := range f.locks {
+		if other == fd {
+			continue
+		}
+		if kind == lockEX || held == lockEX {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockFile releases the lock m holds on its file, if any, waking up
+// waiters.
+func (m *FakeFileDescriptor) unlockFile() {
+	if _, ok := m.file.locks[m]; !ok {
+		return
+	}
+	delete(m.file.locks, m)
+	if m.fsys != nil {
+		m.fsys.lockCond().Broadcast()
+	}
+}
+
+// lockCond returns the condition Flock waits on, must be called with mu
+// held for writing.
+func (m *FakeFileSystem) lockCond() *sync.Cond {
+	if m.flockCond == nil {
+		m.flockCond = sync.NewCond(&m.mu)
+	}
+	return m.flockCond
+}
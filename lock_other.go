@@ -0,0 +1,11 @@
+//go:build !unix
+
+package ffs
+
+// flock(2) operations, with the values they have on Linux
+const (
+	lockSH = 1
+	lockEX = 2
+	lockNB = 4
+	lockUN = 8
+)
@@ -0,0 +1,117 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func openLocked(t *testing.T, m *FakeFileSystem, how int) *FakeFileDescriptor {
+	t.Helper()
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.(*FakeFileDescriptor).Flock(how); err != nil {
+		t.Fatal(err)
+	}
+	return fd.(*FakeFileDescriptor)
+}
+
+func TestFlock(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	sh1 := openLocked(t, m, lockSH)
+	sh2 := openLocked(t, m, lockSH|lockNB)
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := fd.(*FakeFileDescriptor)
+	defer ex.Close()
+	if err := ex.Flock(lockEX | lockNB); !errors.Is(err, syscall.EWOULDBLOCK) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EWOULDBLOCK)
+	}
+	if err := sh1.Flock(lockUN); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Flock(lockEX | lockNB); err != nil {
+		t.Errorf("lock not released: %v", err)
+	}
+	if err := sh1.Flock(lockSH | lockNB); !errors.Is(err, syscall.EWOULDBLOCK) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EWOULDBLOCK)
+	}
+	sh1.Close()
+}
+
+func TestFlockBlocks(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	ex := openLocked(t, m, lockEX)
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	acquired := make(chan error)
+	go func() {
+		acquired <- fd.(*FakeFileDescriptor).Flock(lockEX)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a conflicting lock")
+	case <-time.After(10 * time.Millisecond):
+	}
+	ex.Close()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lock not acquired after the conflicting one was released")
+	}
+}
+
+func TestRemoveDropsLocksAndXattrs(t *testing.T) {
+	for name, remove := range map[string]func(*FakeFileSystem) error{
+		"Remove":    func(m *FakeFileSystem) error { return m.Remove(testFilePath) },
+		"RemoveAll": func(m *FakeFileSystem) error { return m.RemoveAll(testFileDir) },
+	} {
+		m := MockFS(
+			WithFile(testFilePath, []byte(testContent)),
+		)
+		if err := m.Setxattr(testFilePath, "user.test", []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		old := openLocked(t, m, lockEX)
+		if err := remove(m); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.MkdirAll(testFileDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.WriteFile(testFilePath, []byte(testContent), testPerm); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Getxattr(testFilePath, "user.test"); !errors.Is(err, syscall.ENODATA) {
+			t.Errorf("%s: got: `%v', want: `%v'", name, err, syscall.ENODATA)
+		}
+		fd, err := m.OpenFile(testFilePath, os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fd.(*FakeFileDescriptor).Flock(lockEX | lockNB); err != nil {
+			t.Errorf("%s: new file is still locked: %v", name, err)
+		}
+		fd.Close()
+		old.Close()
+	}
+}
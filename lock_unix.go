@@ -0,0 +1,13 @@
+//go:build unix
+
+package ffs
+
+import "syscall"
+
+// flock(2) operations
+const (
+	lockSH = syscall.LOCK_SH
+	lockEX = syscall.LOCK_EX
+	lockNB = syscall.LOCK_NB
+	lockUN = syscall.LOCK_UN
+)
@@ -0,0 +1,156 @@
+package ffs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// LoadFromDir creates a FakeFileSystem mirroring the directory tree at root
+// of real (usually a RealFileSystem): root becomes "/" of the fake, with the
+// same structure, file contents, modes, and modification times.
+// Symlinks are preserved as symlinks, with their target unchanged (so an
+// absolute target still refers to a path of real, not of the fake), but
+// their modification time is the time they were loaded at.
+func LoadFromDir(real FileSystem, root string) (*FakeFileSystem, error) {
+	m := MockFS()
+	type dir struct {
+		path    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	// the mode and times of directories are applied last, as adding their
+	// entries changes the times, and the mode might not allow adding them
+	var dirs []dir
+	err := real.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Join("/", rel)
+		fi, err := real.Lstat(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case fi.IsDir():
+			if name != "/" {
+				if err := m.Mkdir(name, 0700); err != nil {
+					return err
+				}
+			}
+			dirs = append(dirs, dir{name, fi.Mode(), fi.ModTime()})
+		case fi.Mode().Type() == fs.ModeSymlink:
+			target, err := real.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return m.Symlink(target, name)
+		default:
+			data, err := real.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := m.WriteFile(name, data, 0600); err != nil {
+				return err
+			}
+			if err := m.Chmod(name, fi.Mode()); err != nil {
+				return err
+			}
+			return m.Chtimes(name, time.Time{}, fi.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := m.Chmod(d.path, d.mode); err != nil {
+			return nil, err
+		}
+		if err := m.Chtimes(d.path, time.Time{}, d.modTime); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WriteToDir materializes the tree of the fake onto real (usually a
+// RealFileSystem), with "/" of the fake becoming root: directories are
+// created, files written, and symlinks created, with the modes and
+// modification times of the fake applied to them.
+// Directories that already exist are merged into, but if any other entry
+// of the tree already exists below root, WriteToDir fails with EEXIST,
+// before anything is written, unless overwrite is set, in which case the
+// existing entries are replaced.
+func (m *FakeFileSystem) WriteToDir(real FileSystem, root string, overwrite bool) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var nodes []*FakeFile
+	collect(m.root, &nodes)
+	// parents before their children
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].path < nodes[j].path
+	})
+	target := func(f *FakeFile) string {
+		return filepath.Join(root, f.path)
+	}
+
+	if !overwrite {
+		for _, f := range nodes {
+			fi, err := real.Lstat(target(f))
+			if err != nil {
+				continue
+			}
+			if !f.isDir || !fi.IsDir() {
+				return &os.PathError{
+					Op:   "open",
+					Path: target(f),
+					Err:  syscall.EEXIST,
+				}
+			}
+		}
+	}
+
+	for _, f := range nodes {
+		path := target(f)
+		if fi, err := real.Lstat(path); err == nil && !(f.isDir && fi.IsDir()) {
+			if err := real.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch {
+		case f.isDir:
+			err = real.MkdirAll(path, 0700)
+		case f.symlink:
+			err = real.Symlink(f.linkTarget, path)
+		default:
+			err = real.WriteFile(path, f.bytes, 0600)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// children first, as writing an entry changes the times of its directory
+	for i := len(nodes) - 1; i >= 0; i-- {
+		f := nodes[i]
+		if f.symlink {
+			continue
+		}
+		if err := real.Chmod(target(f), f.mode&chmodBits); err != nil {
+			return err
+		}
+		if err := real.Chtimes(target(f), time.Time{}, f.lastMod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestLoadFromDir(t *testing.T) {
+	real := &RealFileSystem{}
+	root := filepath.Join("testdata", "tree")
+	m, err := LoadFromDir(real, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	err = real.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		name := filepath.Join("/", rel)
+		paths = append(paths, name)
+		want, err := real.Lstat(path)
+		if err != nil {
+			return err
+		}
+		got, err := m.Lstat(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return nil
+		}
+		if got.Mode() != want.Mode() {
+			t.Errorf("%s: got: `%v', want: `%v'", name, got.Mode(), want.Mode())
+		}
+		// symlinks get the time they were created at, there is no lchtimes
+		if want.Mode().Type() != fs.ModeSymlink && !got.ModTime().Equal(want.ModTime()) {
+			t.Errorf("%s: got: `%v', want: `%v'", name, got.ModTime(), want.ModTime())
+		}
+		switch {
+		case want.Mode().Type() == fs.ModeSymlink:
+			wantTarget, _ := real.Readlink(path)
+			gotTarget, err := m.Readlink(name)
+			if err != nil || gotTarget != wantTarget {
+				t.Errorf("%s: got: `%s' (%v), want: `%s'", name, gotTarget, err, wantTarget)
+			}
+		case want.Mode().IsRegular():
+			wantData, _ := real.ReadFile(path)
+			gotData, err := m.ReadFile(name)
+			if err != nil || string(gotData) != string(wantData) {
+				t.Errorf("%s: got: `%s' (%v), want: `%s'", name, gotData, err, wantData)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.contents) != len(paths) {
+		t.Errorf("got: %d files, want: %d", len(m.contents), len(paths))
+	}
+}
+
+func TestWriteToDir(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithDirectory("/empty"),
+	)
+	if err := m.Symlink(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	real := &RealFileSystem{}
+	root := t.TempDir()
+	if err := m.WriteToDir(real, root, false); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := real.ReadFile(filepath.Join(root, testFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	fi, err := real.Stat(filepath.Join(root, testFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 {
+		t.Errorf("got: `%v', want: `%v'", fi.Mode(), fs.FileMode(0600))
+	}
+	if fi, err := real.Stat(filepath.Join(root, "/empty")); err != nil || !fi.IsDir() {
+		t.Errorf("got: `%v' (%v), want: directory", fi, err)
+	}
+	target, err := real.Readlink(filepath.Join(root, "/link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != testFilePath {
+		t.Errorf("got: `%s', want: `%s'", target, testFilePath)
+	}
+
+	// loading it back results in the same tree
+	l, err := LoadFromDir(real, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.String(), m.String(); got != want {
+		t.Errorf("got: `%s', want: `%s'", got, want)
+	}
+}
+
+func TestWriteToDirConflict(t *testing.T) {
+	real := &RealFileSystem{}
+	root := t.TempDir()
+	conflict := filepath.Join(root, testFilePath)
+	if err := real.MkdirAll(filepath.Dir(conflict), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := real.WriteFile(conflict, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/other.txt", []byte(testContent)),
+	)
+	err := m.WriteToDir(real, root, false)
+	if !errors.Is(err, syscall.EEXIST) {
+		t.Fatalf("got: `%v', want: `%v'", err, syscall.EEXIST)
+	}
+	// nothing was written
+	if _, err := real.Stat(filepath.Join(root, "/other.txt")); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+
+	if err := m.WriteToDir(real, root, true); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := real.ReadFile(conflict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
@@ -0,0 +1,750 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// overlayFS is a union of two file systems, see NewOverlay.
+type overlayFS struct {
+	// mu serializes the operations, which consist of several steps on the
+	// layers, and guards the whiteouts.
+	mu           sync.RWMutex
+	lower, upper FileSystem
+	whiteouts    map[string]bool // removed from the view, even though in lower
+	opaque       map[string]bool // directories hiding the contents of lower
+}
+
+var _ FileSystem = (*overlayFS)(nil)
+
+// NewOverlay returns a union of lower and upper, modeling overlayfs(5):
+// files are looked up in upper first, falling through to lower if they are
+// missing there. Every modification goes to upper, lower is never written
+// to: a file of lower that is opened for writing (or chmod'ed, truncated,
+// ...) is first copied up, and removing a file of lower records a whiteout,
+// hiding it from the view. A directory created where a whiteout was is
+// opaque, the contents of lower below it stay hidden.
+// Whiteouts are kept in the overlay itself, not in upper.
+// Directories of lower can't be renamed (EXDEV), just like on overlayfs
+// without redirect_dir.
+// Symlinks are resolved within the layer that holds them, and descriptors
+// opened on directories only list the entries of their layer, use ReadDir
+// for the merged view.
+// Paths are not resolved against a working directory: use absolute paths.
+func NewOverlay(lower, upper FileSystem) FileSystem {
+	return &overlayFS{
+		lower:     lower,
+		upper:     upper,
+		whiteouts: map[string]bool{},
+		opaque:    map[string]bool{},
+	}
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// reop makes err, if it's a *os.PathError, refer to op and path.
+func reop(err error, op, path string) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return &os.PathError{
+			Op:   op,
+			Path: path,
+			Err:  pe.Err,
+		}
+	}
+	return err
+}
+
+// hidden reports whether the lower file at path is hidden from the view,
+// because it or one of its ancestors was removed, or one of its ancestors
+// is opaque.
+func (o *overlayFS) hidden(path string) bool {
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if o.whiteouts[p] {
+			return true
+		}
+		if p != filepath.Clean(path) && o.opaque[p] {
+			return true
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
+
+// inLower reports whether the file at path of lower is part of the view.
+func (o *overlayFS) inLower(path string) bool {
+	if o.hidden(path) {
+		return false
+	}
+	_, err := o.lower.Lstat(path)
+	return err == nil
+}
+
+// layer returns the layer holding the file at path.
+func (o *overlayFS) layer(op, path string) (FileSystem, error) {
+	_, err := o.upper.Lstat(path)
+	if err == nil {
+		return o.upper, nil
+	}
+	if !isNotExist(err) {
+		// e.g. ENOTDIR, a file of upper shadows a directory of lower
+		return nil, reop(err, op, path)
+	}
+	if o.hidden(path) {
+		return nil, &os.PathError{
+			Op:   op,
+			Path: path,
+			Err:  syscall.ENOENT,
+		}
+	}
+	if _, err := o.lower.Lstat(path); err != nil {
+		return nil, reop(err, op, path)
+	}
+	return o.lower, nil
+}
+
+// copyUpDir creates the directory dir and its ancestors in upper, copying
+// them from lower.
+func (o *overlayFS) copyUpDir(op, dir string) error {
+	if dir == "/" || dir == "." {
+		return nil
+	}
+	fi, err := o.upper.Lstat(dir)
+	if err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{
+				Op:   op,
+				Path: dir,
+				Err:  syscall.ENOTDIR,
+			}
+		}
+		return nil
+	}
+	if !isNotExist(err) {
+		return reop(err, op, dir)
+	}
+	if o.hidden(dir) {
+		return &os.PathError{
+			Op:   op,
+			Path: dir,
+			Err:  syscall.ENOENT,
+		}
+	}
+	fi, err = o.lower.Lstat(dir)
+	if err != nil {
+		return reop(err, op, dir)
+	}
+	if !fi.IsDir() {
+		return &os.PathError{
+			Op:   op,
+			Path: dir,
+			Err:  syscall.ENOTDIR,
+		}
+	}
+	if err := o.copyUpDir(op, filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(dir, 0700); err != nil {
+		return err
+	}
+	if err := o.upper.Chmod(dir, fi.Mode()&chmodBits); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(dir, time.Time{}, fi.ModTime())
+}
+
+// copyUp copies the file at path from lower to upper, if it's not in upper
+// already. Directories are copied without their contents.
+func (o *overlayFS) copyUp(op, path string) error {
+	if _, err := o.upper.Lstat(path); err == nil {
+		return nil
+	}
+	if err := o.copyUpDir(op, filepath.Dir(path)); err != nil {
+		return err
+	}
+	fi, err := o.lower.Lstat(path)
+	if err != nil {
+		return reop(err, op, path)
+	}
+	switch {
+	case fi.IsDir():
+		return o.copyUpDir(op, path)
+	case fi.Mode().Type() == fs.ModeSymlink:
+		target, err := o.lower.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return o.upper.Symlink(target, path)
+	default:
+		data, err := o.lower.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := o.upper.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+		if err := o.upper.Chmod(path, fi.Mode()&chmodBits); err != nil {
+			return err
+		}
+		return o.upper.Chtimes(path, time.Time{}, fi.ModTime())
+	}
+}
+
+// prepareCreate makes sure that a file can be created at path in upper.
+func (o *overlayFS) prepareCreate(op, path string) error {
+	if err := o.copyUpDir(op, filepath.Dir(path)); err != nil {
+		return err
+	}
+	delete(o.whiteouts, filepath.Clean(path))
+	return nil
+}
+
+func (o *overlayFS) Create(path string) (File, error) {
+	return o.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (o *overlayFS) Open(path string) (File, error) {
+	return o.OpenFile(path, os.O_RDONLY, 0)
+}
+
+func (o *overlayFS) Stat(path string) (fs.FileInfo, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	l, err := o.layer("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Stat(path)
+}
+
+func (o *overlayFS) Lstat(path string) (fs.FileInfo, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	l, err := o.layer("lstat", path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lstat(path)
+}
+
+func (o *overlayFS) OpenFile(path string, flag int, perm fs.FileMode) (File, error) {
+	if !canWrite(flag) && flag&(os.O_CREATE|os.O_TRUNC) == 0 {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		l, err := o.layer("open", path)
+		if err != nil {
+			return nil, err
+		}
+		return l.OpenFile(path, flag, perm)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, err := o.layer("open", path)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  syscall.EEXIST,
+		}
+	case err == nil && l == o.lower:
+		if err := o.copyUp("open", path); err != nil {
+			return nil, err
+		}
+	case err == nil:
+	case isNotExist(err) && flag&os.O_CREATE != 0:
+		if err := o.prepareCreate("open", path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return o.upper.OpenFile(path, flag, perm)
+}
+
+func (o *overlayFS) Mkdir(path string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.mkdir(path, perm)
+}
+
+func (o *overlayFS) mkdir(path string, perm fs.FileMode) error {
+	if _, err := o.layer("mkdir", path); err == nil {
+		return &os.PathError{
+			Op:   "mkdir",
+			Path: path,
+			Err:  syscall.EEXIST,
+		}
+	} else if !isNotExist(err) {
+		return err
+	}
+	path = filepath.Clean(path)
+	if err := o.copyUpDir("mkdir", filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := o.upper.Mkdir(path, perm); err != nil {
+		return err
+	}
+	if o.whiteouts[path] {
+		delete(o.whiteouts, path)
+		o.opaque[path] = true
+	}
+	return nil
+}
+
+func (o *overlayFS) MkdirAll(path string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.mkdirAll(path, perm)
+}
+
+func (o *overlayFS) mkdirAll(path string, perm fs.FileMode) error {
+	path = filepath.Clean(path)
+	if l, err := o.layer("mkdir", path); err == nil {
+		fi, err := l.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return &os.PathError{
+				Op:   "mkdir",
+				Path: path,
+				Err:  syscall.ENOTDIR,
+			}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(path); parent != path {
+		if err := o.mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	return o.mkdir(path, perm)
+}
+
+// ReadDir lists the entries of both layers, sorted by name: entries of upper
+// shadow those of lower with the same name, and removed entries of lower
+// are left out, as are all entries of lower if the directory is opaque.
+func (o *overlayFS) ReadDir(path string) ([]fs.DirEntry, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.readDir(path)
+}
+
+func (o *overlayFS) readDir(path string) ([]fs.DirEntry, error) {
+	path = filepath.Clean(path)
+	upper, err := o.upper.ReadDir(path)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	inUpper := err == nil
+	var lower []fs.DirEntry
+	if !o.opaque[path] && !o.hidden(path) {
+		lower, err = o.lower.ReadDir(path)
+		if err != nil {
+			if !inUpper {
+				return nil, err
+			}
+			// shadowed by the directory of upper
+			lower = nil
+		}
+	} else if !inUpper {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: path,
+			Err:  syscall.ENOENT,
+		}
+	}
+	entries := upper
+	seen := map[string]bool{}
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if seen[e.Name()] || o.whiteouts[filepath.Join(path, e.Name())] {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (o *overlayFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	fi, err := o.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = o.walkDir(root, fs.FileInfoToDirEntry(fi), fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDir walks the merged tree like filepath.WalkDir.
+func (o *overlayFS) walkDir(path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := o.ReadDir(path)
+	if err != nil {
+		err = fn(path, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := o.walkDir(filepath.Join(path, e.Name()), e, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *overlayFS) Truncate(path string, size int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.layer("truncate", path); err != nil {
+		return err
+	}
+	if err := o.copyUp("truncate", path); err != nil {
+		return err
+	}
+	return o.upper.Truncate(path, size)
+}
+
+func (o *overlayFS) ReadFile(path string) ([]byte, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	l, err := o.layer("open", path)
+	if err != nil {
+		return nil, err
+	}
+	return l.ReadFile(path)
+}
+
+func (o *overlayFS) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, err := o.layer("open", path)
+	switch {
+	case err == nil && l == o.lower:
+		if err := o.copyUp("open", path); err != nil {
+			return err
+		}
+	case err == nil:
+	case isNotExist(err):
+		if err := o.prepareCreate("open", path); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+	return o.upper.WriteFile(path, data, perm)
+}
+
+func (o *overlayFS) Remove(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, err := o.layer("remove", path)
+	if err != nil {
+		return err
+	}
+	fi, err := l.Lstat(path)
+	if err != nil {
+		return reop(err, "remove", path)
+	}
+	if fi.IsDir() {
+		entries, err := o.readDir(path)
+		if err != nil {
+			return reop(err, "remove", path)
+		}
+		if len(entries) > 0 {
+			return &os.PathError{
+				Op:   "remove",
+				Path: path,
+				Err:  syscall.ENOTEMPTY,
+			}
+		}
+	}
+	if l == o.upper {
+		if err := o.upper.Remove(path); err != nil {
+			return err
+		}
+	}
+	o.whiteout(path)
+	return nil
+}
+
+// whiteout hides the file of lower at path, if there is one, after the
+// file at path was removed from upper.
+func (o *overlayFS) whiteout(path string) {
+	path = filepath.Clean(path)
+	delete(o.opaque, path)
+	if o.inLower(path) {
+		o.whiteouts[path] = true
+	}
+}
+
+func (o *overlayFS) RemoveAll(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, err := o.layer("remove", path)
+	if isNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(path) == "/" {
+		return &os.PathError{
+			Op:   "remove",
+			Path: path,
+			Err:  syscall.EPERM,
+		}
+	}
+	if l == o.upper {
+		if err := o.upper.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	o.whiteout(path)
+	return nil
+}
+
+func (o *overlayFS) Rename(oldpath, newpath string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	linkErr := func(err error) error {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return &os.LinkError{
+			Op:  "rename",
+			Old: oldpath,
+			New: newpath,
+			Err: err,
+		}
+	}
+	l, err := o.layer("rename", oldpath)
+	if err != nil {
+		return linkErr(err)
+	}
+	fi, err := l.Lstat(oldpath)
+	if err != nil {
+		return linkErr(err)
+	}
+	if fi.IsDir() && o.inLower(oldpath) {
+		return linkErr(syscall.EXDEV)
+	}
+	if nl, err := o.layer("rename", newpath); err == nil {
+		nfi, err := nl.Lstat(newpath)
+		if err != nil {
+			return linkErr(err)
+		}
+		switch {
+		case fi.IsDir() && !nfi.IsDir():
+			return linkErr(syscall.ENOTDIR)
+		case !fi.IsDir() && nfi.IsDir():
+			return linkErr(syscall.EISDIR)
+		case nfi.IsDir():
+			if entries, err := o.readDir(newpath); err != nil {
+				return linkErr(err)
+			} else if len(entries) > 0 {
+				return linkErr(syscall.ENOTEMPTY)
+			}
+		}
+	} else if !isNotExist(err) {
+		return linkErr(err)
+	}
+	if err := o.copyUp("rename", oldpath); err != nil {
+		return linkErr(err)
+	}
+	if err := o.copyUpDir("rename", filepath.Dir(filepath.Clean(newpath))); err != nil {
+		return linkErr(err)
+	}
+	if err := o.upper.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	o.whiteout(oldpath)
+	newpath = filepath.Clean(newpath)
+	delete(o.whiteouts, newpath)
+	if fi.IsDir() && o.inLower(newpath) {
+		o.opaque[newpath] = true
+	}
+	return nil
+}
+
+// Link copies oldname up, if it's a file of lower, and links it in upper.
+func (o *overlayFS) Link(oldname, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	linkErr := func(err error) error {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return &os.LinkError{
+			Op:  "link",
+			Old: oldname,
+			New: newname,
+			Err: err,
+		}
+	}
+	if _, err := o.layer("link", oldname); err != nil {
+		return linkErr(err)
+	}
+	if _, err := o.layer("link", newname); err == nil {
+		return linkErr(syscall.EEXIST)
+	} else if !isNotExist(err) {
+		return linkErr(err)
+	}
+	if err := o.copyUp("link", oldname); err != nil {
+		return linkErr(err)
+	}
+	if err := o.prepareCreate("link", newname); err != nil {
+		return linkErr(err)
+	}
+	return o.upper.Link(oldname, newname)
+}
+
+func (o *overlayFS) Symlink(oldname, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	linkErr := func(err error) error {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return &os.LinkError{
+			Op:  "symlink",
+			Old: oldname,
+			New: newname,
+			Err: err,
+		}
+	}
+	if _, err := o.layer("symlink", newname); err == nil {
+		return linkErr(syscall.EEXIST)
+	} else if !isNotExist(err) {
+		return linkErr(err)
+	}
+	if err := o.prepareCreate("symlink", newname); err != nil {
+		return linkErr(err)
+	}
+	return o.upper.Symlink(oldname, newname)
+}
+
+func (o *overlayFS) Readlink(name string) (string, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	l, err := o.layer("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return l.Readlink(name)
+}
+
+func (o *overlayFS) Chmod(path string, mode fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.layer("chmod", path); err != nil {
+		return err
+	}
+	if err := o.copyUp("chmod", path); err != nil {
+		return err
+	}
+	return o.upper.Chmod(path, mode)
+}
+
+func (o *overlayFS) Chtimes(path string, atime, mtime time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.layer("chtimes", path); err != nil {
+		return err
+	}
+	if err := o.copyUp("chtimes", path); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(path, atime, mtime)
+}
+
+// tempDir copies dir (or TempDir, if it's empty) up, if it is a directory of
+// lower, so that a temporary file can be created in it.
+func (o *overlayFS) tempDir(op, dir string) error {
+	if dir == "" {
+		dir = TempDir
+	}
+	if err := o.copyUpDir(op, filepath.Clean(dir)); err != nil && !isNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (o *overlayFS) CreateTemp(dir, pattern string) (File, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.tempDir("createtemp", dir); err != nil {
+		return nil, err
+	}
+	return o.upper.CreateTemp(dir, pattern)
+}
+
+func (o *overlayFS) MkdirTemp(dir, pattern string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.tempDir("mkdirtemp", dir); err != nil {
+		return "", err
+	}
+	return o.upper.MkdirTemp(dir, pattern)
+}
+
+func (o *overlayFS) Glob(pattern string) ([]string, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	matches, err := o.upper.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := o.lower.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, m := range matches {
+		seen[m] = true
+	}
+	for _, m := range lower {
+		if seen[m] {
+			continue
+		}
+		if l, err := o.layer("lstat", m); err != nil || l != o.lower {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
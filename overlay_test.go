@@ -0,0 +1,208 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func newTestOverlay() (lower, upper *FakeFileSystem, o FileSystem) {
+	lower = MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/etc/hosts", []byte("127.0.0.1 localhost")),
+	)
+	upper = MockFS()
+	return lower, upper, NewOverlay(lower, upper)
+}
+
+func TestOverlayReadThrough(t *testing.T) {
+	_, upper, o := newTestOverlay()
+	bs, err := o.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	if _, err := o.Stat("/etc/hosts"); err != nil {
+		t.Error(err)
+	}
+	// reading doesn't copy up
+	if _, err := upper.Stat(testFilePath); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+
+	// upper shadows lower
+	if err := upper.MkdirAll(testFileDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := upper.WriteFile(testFilePath, []byte("upper"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	bs, err = o.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "upper" {
+		t.Errorf("got: `%s', want: `upper'", bs)
+	}
+}
+
+func TestOverlayCopyUp(t *testing.T) {
+	lower, upper, o := newTestOverlay()
+	if err := lower.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := o.OpenFile(testFilePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte(" appended")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := testContent + " appended"
+	bs, err := o.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != want {
+		t.Errorf("got: `%s', want: `%s'", bs, want)
+	}
+	bs, err = upper.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != want {
+		t.Errorf("got: `%s', want: `%s'", bs, want)
+	}
+	fi, err := upper.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0600 {
+		t.Errorf("got: `%v', want: `%v'", fi.Mode(), os.FileMode(0600))
+	}
+	// lower stays pristine
+	bs, err = lower.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestOverlayWhiteout(t *testing.T) {
+	lower, _, o := newTestOverlay()
+	if err := o.Remove(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Stat(testFilePath); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+	if _, err := lower.Stat(testFilePath); err != nil {
+		t.Errorf("removed from lower: %v", err)
+	}
+	if err := o.Remove(testFilePath); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+
+	// creating the file again replaces the whiteout
+	if err := o.WriteFile(testFilePath, []byte("new"), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := o.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "new" {
+		t.Errorf("got: `%s', want: `new'", bs)
+	}
+}
+
+func TestOverlayOpaqueDir(t *testing.T) {
+	_, _, o := newTestOverlay()
+	if err := o.RemoveAll("/etc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Mkdir("/etc", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Stat("/etc/hosts"); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+	entries, err := o.ReadDir("/etc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("got: `%v', want: no entries", entries)
+	}
+}
+
+func TestOverlayRenameLowerDir(t *testing.T) {
+	_, _, o := newTestOverlay()
+	if err := o.Rename("/etc", "/moved"); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EXDEV)
+	}
+	if err := o.Rename("/etc/hosts", "/hosts"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Stat("/etc/hosts"); !os.IsNotExist(err) {
+		t.Errorf("got: `%v', want: not exist", err)
+	}
+	if _, err := o.Stat("/hosts"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOverlayReadDirMerge(t *testing.T) {
+	lower := MockFS(
+		WithFile("/dir/a", []byte("lower")),
+		WithFile("/dir/both", []byte("lower")),
+		WithFile("/dir/gone", []byte("lower")),
+		WithFile("/dir/z", []byte("lower")),
+	)
+	upper := MockFS(
+		WithFile("/dir/both", []byte("upper version")),
+		WithFile("/dir/c", []byte("upper version")),
+	)
+	o := NewOverlay(lower, upper)
+	if err := o.Remove("/dir/gone"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := o.ReadDir("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name string
+		size int64
+	}{
+		{"a", int64(len("lower"))},
+		{"both", int64(len("upper version"))},
+		{"c", int64(len("upper version"))},
+		{"z", int64(len("lower"))},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("got: `%v', want: `%v'", entries, expected)
+	}
+	for i, e := range expected {
+		if entries[i].Name() != e.name {
+			t.Errorf("got: `%s', want: `%s'", entries[i].Name(), e.name)
+			continue
+		}
+		fi, err := entries[i].Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != e.size {
+			t.Errorf("%s: got: %d, want: %d", e.name, fi.Size(), e.size)
+		}
+	}
+}
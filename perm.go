@@ -0,0 +1,42 @@
+package ffs
+
+import "io/fs"
+
+// WithUser enforces permissions for the user uid, who is a member of the
+// group gid: files created by the user are owned by it, and the permission
+// bits of the owner, the group, or others apply to the user, depending on
+// who owns the file. Files created by options that come before WithUser are
+// owned by root (uid and gid 0). Like on a real system, root may access any
+// file.
+//
+// Permissions are currently only enforced when listing directories (e.g. by
+// WalkDir).
+func WithUser(uid, gid int) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.enforcePerms = true
+		fs.uid, fs.gid = uid, gid
+	}
+}
+
+// Permission bits requested from canAccess.
+const (
+	permRead    fs.FileMode = 4
+	permWrite   fs.FileMode = 2
+	permExecute fs.FileMode = 1
+)
+
+// canAccess reports whether the acting user has the permissions want (an
+// or-ed combination of permRead, permWrite, and permExecute) on f.
+func (m *FakeFileSystem) canAccess(f *FakeFile, want fs.FileMode) bool {
+	if !m.enforcePerms || m.uid == 0 {
+		return true
+	}
+	perm := f.mode.Perm()
+	switch {
+	case f.uid == m.uid:
+		perm >>= 6
+	case f.gid == m.gid:
+		perm >>= 3
+	}
+	return perm&want == want
+}
@@ -0,0 +1,119 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestWalkDirPermissionDeniedRoot(t *testing.T) {
+	m := MockFS(
+		WithFile("/secret/a.txt", []byte(testContent)),
+		WithUser(1000, 1000),
+	)
+	if err := m.Chmod("/secret", 0); err != nil {
+		t.Fatal(err)
+	}
+	sentinel := errors.New("sentinel")
+	calls := 0
+	err := m.WalkDir("/secret", func(path string, d fs.DirEntry, err error) error {
+		calls++
+		if path != "/secret" {
+			t.Errorf("got: `%s', want: `/secret'", path)
+		}
+		if !errors.Is(err, syscall.EACCES) {
+			t.Errorf("got: `%v', want: `%v'", err, syscall.EACCES)
+		}
+		return sentinel
+	})
+	if err != sentinel {
+		t.Errorf("got: `%v', want: `%v'", err, sentinel)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want: 1", calls)
+	}
+}
+
+func TestWalkDirPermissionDeniedSubdir(t *testing.T) {
+	m := MockFS(
+		WithFile("/dir/secret/a.txt", []byte(testContent)),
+		WithUser(1000, 1000),
+	)
+	if err := m.Chmod("/dir/secret", 0); err != nil {
+		t.Fatal(err)
+	}
+	var denied []string
+	err := m.WalkDir("/dir", func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, syscall.EACCES) {
+			denied = append(denied, path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if path == "/dir/secret/a.txt" {
+			t.Errorf("walked into unreadable directory")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denied) != 1 || denied[0] != "/dir/secret" {
+		t.Errorf("got: `%v', want: `[/dir/secret]'", denied)
+	}
+}
+
+func TestWalkDirPermissionFileRoot(t *testing.T) {
+	m := MockFS(
+		WithFile("/secret.txt", []byte(testContent)),
+		WithUser(1000, 1000),
+	)
+	if err := m.Chmod("/secret.txt", 0); err != nil {
+		t.Fatal(err)
+	}
+	// like filepath.WalkDir, a file root is only reported, never opened
+	calls := 0
+	err := m.WalkDir("/secret.txt", func(path string, d fs.DirEntry, err error) error {
+		calls++
+		if err != nil {
+			t.Errorf("got: `%v', want: `<nil>'", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want: 1", calls)
+	}
+}
+
+func TestWithUserOwner(t *testing.T) {
+	m := MockFS(
+		WithUser(1000, 1000),
+	)
+	if err := m.Mkdir("/mine", 0700); err != nil {
+		t.Fatal(err)
+	}
+	// the owner's bits apply, so the user may still list the directory
+	if err := m.WalkDir("/mine", func(path string, d fs.DirEntry, err error) error {
+		return err
+	}); err != nil {
+		t.Errorf("got: `%v', want: `<nil>'", err)
+	}
+	// root may list anything
+	r := MockFS(
+		WithFile("/secret/a.txt", []byte(testContent)),
+		WithUser(0, 0),
+	)
+	if err := r.Chmod("/secret", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WalkDir("/secret", func(path string, d fs.DirEntry, err error) error {
+		return err
+	}); err != nil {
+		t.Errorf("got: `%v', want: `<nil>'", err)
+	}
+}
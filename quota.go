@@ -0,0 +1,64 @@
+package ffs
+
+import (
+	"os"
+	"syscall"
+)
+
+// WithCapacity limits the total number of bytes stored in the file system.
+// Writes (Write, WriteAt, and WriteFile) that would exceed it fail with
+// ENOSPC, without writing anything: the file keeps its previous content.
+func WithCapacity(bytes int64) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.capacity = bytes
+	}
+}
+
+// Usage reports the total number of bytes stored in the file system.
+// This includes removed files that are still open: their space is only freed
+// once the last descriptor is closed.
+func (m *FakeFileSystem) Usage() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.usage()
+}
+
+func (m *FakeFileSystem) usage() int64 {
+	var n int64
+	counted := map[*inode]bool{} // hard links share their space
+	for _, f := range m.contents {
+		if !f.isDir && !f.symlink && !counted[f.inode] {
+			counted[f.inode] = true
+			n += int64(len(f.bytes))
+		}
+	}
+	for ino := range m.orphans {
+		n += int64(len(ino.bytes))
+	}
+	return n
+}
+
+// checkSpace fails with ENOSPC if storing grow more bytes would exceed the
+// capacity.
+func (m *FakeFileSystem) checkSpace(grow int64) error {
+	if m.capacity > 0 && grow > 0 && m.usage()+grow > m.capacity {
+		return syscall.ENOSPC
+	}
+	return nil
+}
+
+// checkSpace fails with ENOSPC if writing n bytes at off would make the file
+// exceed the capacity of the file system.
+func (m *FakeFileDescriptor) checkSpace(n int, off int64) error {
+	if m.fsys == nil {
+		return nil
+	}
+	if err := m.fsys.checkSpace(off + int64(n) - int64(len(m.data()))); err != nil {
+		return &os.PathError{
+			Op:   "write",
+			Path: m.file.path,
+			Err:  err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestCapacity(t *testing.T) {
+	m := MockFS(
+		WithCapacity(100),
+		WithFile("/a", make([]byte, 60)),
+	)
+	if usage := m.Usage(); usage != 60 {
+		t.Errorf("got: %d, want: 60", usage)
+	}
+
+	fd, err := m.OpenFile("/b", os.O_RDWR|os.O_CREATE, testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	// fill up to the limit
+	if _, err := fd.Write([]byte(testContent[:40])); err != nil {
+		t.Fatal(err)
+	}
+	if usage := m.Usage(); usage != 100 {
+		t.Errorf("got: %d, want: 100", usage)
+	}
+
+	_, err = fd.Write([]byte("x"))
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	_, err = fd.WriteAt([]byte("xy"), 39)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	err = m.WriteFile("/c", []byte("x"), testPerm)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	if _, err := m.Stat("/c"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+
+	// the existing data is intact
+	bs, err := m.ReadFile("/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent[:40] {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent[:40])
+	}
+
+	// overwriting within the file doesn't need more space
+	if _, err := fd.WriteAt([]byte("ICH"), 0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCapacityWriteFileKeepsOldContent(t *testing.T) {
+	m := MockFS(
+		WithCapacity(int64(len(testContent))+10),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.WriteFile(testFilePath, make([]byte, len(testContent)+11), testPerm)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	// WriteFile fails atomically, instead of leaving the file truncated
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+
+	// replacing the content with data that fits works
+	if err := m.WriteFile(testFilePath, make([]byte, len(testContent)+10), testPerm); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCapacityTruncate(t *testing.T) {
+	m := MockFS(
+		WithCapacity(100),
+		WithFile("/a", make([]byte, 60)),
+	)
+	err := m.Truncate("/a", 1<<30)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+	if usage := m.Usage(); usage != 60 {
+		t.Errorf("got: %d, want: 60", usage)
+	}
+	// growing up to the limit, and shrinking, still works
+	if err := m.Truncate("/a", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Truncate("/a", 10); err != nil {
+		t.Fatal(err)
+	}
+	if usage := m.Usage(); usage != 10 {
+		t.Errorf("got: %d, want: 10", usage)
+	}
+}
+
+func TestRemoveFreesSpace(t *testing.T) {
+	m := MockFS(
+		WithCapacity(100),
+		WithFile("/closed", make([]byte, 60)),
+	)
+	// a file without open descriptors is freed right away
+	if err := m.Remove("/closed"); err != nil {
+		t.Fatal(err)
+	}
+	if usage := m.Usage(); usage != 0 {
+		t.Errorf("got: %d, want: 0", usage)
+	}
+
+	if err := m.WriteFile("/open", make([]byte, 60), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := m.Open("/open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("/open"); err != nil {
+		t.Fatal(err)
+	}
+	// the removed file still takes up space while it's open
+	if usage := m.Usage(); usage != 60 {
+		t.Errorf("got: %d, want: 60", usage)
+	}
+	err = m.WriteFile("/new", make([]byte, 60), testPerm)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOSPC)
+	}
+
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if usage := m.Usage(); usage != 0 {
+		t.Errorf("got: %d, want: 0", usage)
+	}
+	if err := m.WriteFile("/new", make([]byte, 60), testPerm); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,178 @@
+package ffs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ReadOnlyFileSystem wraps a FileSystem, rejecting every mutating operation
+// with EROFS, as if it were mounted read-only.
+// The errors carry the same Op as the wrapped operation would have used.
+// Metadata mutations (Chmod, Chtimes, ...) are mutations too, and are
+// rejected just the same.
+type ReadOnlyFileSystem struct {
+	fsys FileSystem
+}
+
+var _ FileSystem = (*ReadOnlyFileSystem)(nil)
+
+func ReadOnly(fsys FileSystem) *ReadOnlyFileSystem {
+	return &ReadOnlyFileSystem{fsys}
+}
+
+// WithReadOnly makes the file system immutable, as if mounted read-only.
+// Every mutating operation (Create, OpenFile for writing, Write, WriteFile,
+// Truncate, Remove, Rename, Chmod, ...) fails with EROFS, while reading and
+// stat'ing still work. The contents set up by the other options are kept.
+func WithReadOnly() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.readOnly = true
+	}
+}
+
+// Seal makes the file system read-only from now on, as if remounted
+// read-only, see WithReadOnly. Descriptors that are already open for writing
+// fail with EROFS on their next write.
+func (m *FakeFileSystem) Seal() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readOnly = true
+}
+
+// checkWritable fails with EROFS if the file system is read-only.
+func (m *FakeFileSystem) checkWritable(op, path string) error {
+	if m.readOnly {
+		return erofs(op, path)
+	}
+	return nil
+}
+
+func erofs(op, path string) error {
+	return &os.PathError{
+		Op:   op,
+		Path: path,
+		Err:  syscall.EROFS,
+	}
+}
+
+func (r *ReadOnlyFileSystem) Create(path string) (File, error) {
+	return nil, erofs("open", path)
+}
+
+func (r *ReadOnlyFileSystem) Open(path string) (File, error) {
+	return r.fsys.Open(path)
+}
+
+func (r *ReadOnlyFileSystem) Stat(path string) (fs.FileInfo, error) {
+	return r.fsys.Stat(path)
+}
+
+func (r *ReadOnlyFileSystem) Lstat(path string) (fs.FileInfo, error) {
+	return r.fsys.Lstat(path)
+}
+
+func (r *ReadOnlyFileSystem) ReadDir(path string) ([]fs.DirEntry, error) {
+	return r.fsys.ReadDir(path)
+}
+
+func (r *ReadOnlyFileSystem) OpenFile(path string, flag int, perm fs.FileMode) (File, error) {
+	if canWrite(flag) || (flag&os.O_TRUNC) != 0 {
+		return nil, erofs("open", path)
+	}
+	if (flag & os.O_CREATE) != 0 {
+		// only fails if the file would actually have to be created
+		if _, err := r.fsys.Stat(path); err != nil {
+			return nil, erofs("open", path)
+		}
+	}
+	return r.fsys.OpenFile(path, flag, perm)
+}
+
+func (r *ReadOnlyFileSystem) Mkdir(path string, perm fs.FileMode) error {
+	return erofs("mkdir", path)
+}
+
+func (r *ReadOnlyFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	if fi, err := r.fsys.Stat(path); err == nil && fi.IsDir() {
+		return nil
+	}
+	return erofs("mkdir", path)
+}
+
+func (r *ReadOnlyFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return r.fsys.WalkDir(root, fn)
+}
+
+func (r *ReadOnlyFileSystem) Truncate(path string, size int64) error {
+	return erofs("truncate", path)
+}
+
+func (r *ReadOnlyFileSystem) ReadFile(path string) ([]byte, error) {
+	return r.fsys.ReadFile(path)
+}
+
+func (r *ReadOnlyFileSystem) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return erofs("open", path)
+}
+
+func (r *ReadOnlyFileSystem) Remove(path string) error {
+	return erofs("remove", path)
+}
+
+func (r *ReadOnlyFileSystem) RemoveAll(path string) error {
+	return erofs("remove", path)
+}
+
+func (r *ReadOnlyFileSystem) Rename(oldpath, newpath string) error {
+	return &os.LinkError{
+		Op:  "rename",
+		Old: oldpath,
+		New: newpath,
+		Err: syscall.EROFS,
+	}
+}
+
+func (r *ReadOnlyFileSystem) Symlink(oldname, newname string) error {
+	return &os.LinkError{
+		Op:  "symlink",
+		Old: oldname,
+		New: newname,
+		Err: syscall.EROFS,
+	}
+}
+
+func (r *ReadOnlyFileSystem) Link(oldname, newname string) error {
+	return &os.LinkError{
+		Op:  "link",
+		Old: oldname,
+		New: newname,
+		Err: syscall.EROFS,
+	}
+}
+
+func (r *ReadOnlyFileSystem) Readlink(name string) (string, error) {
+	return r.fsys.Readlink(name)
+}
+
+func (r *ReadOnlyFileSystem) Chmod(path string, mode fs.FileMode) error {
+	return erofs("chmod", path)
+}
+
+func (r *ReadOnlyFileSystem) Chtimes(path string, atime, mtime time.Time) error {
+	return erofs("chtimes", path)
+}
+
+func (r *ReadOnlyFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	return nil, erofs("open", filepath.Join(dir, pattern))
+}
+
+func (r *ReadOnlyFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return "", erofs("mkdir", filepath.Join(dir, pattern))
+}
+
+func (r *ReadOnlyFileSystem) Glob(pattern string) ([]string, error) {
+	return r.fsys.Glob(pattern)
+}
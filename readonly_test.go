@@ -0,0 +1,192 @@
+package ffs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyErrorOp(t *testing.T) {
+	r := ReadOnly(MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	))
+	cases := []struct {
+		op  string
+		err error
+	}{
+		{"open", func() error {
+			_, err := r.OpenFile(testFilePath, os.O_RDWR, testPerm)
+			return err
+		}()},
+		{"open", r.WriteFile(testFilePath, []byte(testContent), testPerm)},
+		{"remove", r.Remove(testFilePath)},
+		{"truncate", r.Truncate(testFilePath, 0)},
+	}
+	for _, c := range cases {
+		var pathErr *os.PathError
+		if !errors.As(c.err, &pathErr) {
+			t.Errorf("%s: got: `%v', want: *os.PathError", c.op, c.err)
+			continue
+		}
+		if pathErr.Op != c.op {
+			t.Errorf("got: `%s', want: `%s'", pathErr.Op, c.op)
+		}
+		if pathErr.Err != syscall.EROFS {
+			t.Errorf("%s: got: `%v', want: `%v'", c.op, pathErr.Err, syscall.EROFS)
+		}
+	}
+}
+
+func TestReadOnlyReads(t *testing.T) {
+	r := ReadOnly(MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	))
+	bs, err := r.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	_, err = r.OpenFile(testFilePath, os.O_RDONLY, testPerm)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = r.Stat(testFilePath)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	m := MockFS(
+		WithReadOnly(),
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	cases := []struct {
+		name string
+		err  error
+	}{
+		{"Create", func() error {
+			_, err := m.Create("/new")
+			return err
+		}()},
+		{"OpenFile", func() error {
+			_, err := m.OpenFile(testFilePath, os.O_WRONLY, testPerm)
+			return err
+		}()},
+		{"OpenFile O_CREATE", func() error {
+			_, err := m.OpenFile("/new", os.O_RDONLY|os.O_CREATE, testPerm)
+			return err
+		}()},
+		{"WriteFile", m.WriteFile(testFilePath, []byte(testContent), testPerm)},
+		{"Truncate", m.Truncate(testFilePath, 0)},
+		{"Remove", m.Remove(testFilePath)},
+		{"RemoveAll", m.RemoveAll(testFileDir)},
+		{"Rename", m.Rename(testFilePath, "/new")},
+		{"Mkdir", m.Mkdir("/new", 0755)},
+		{"MkdirAll", m.MkdirAll("/new/dir", 0755)},
+		{"Symlink", m.Symlink(testFilePath, "/new")},
+		{"Chmod", m.Chmod(testFilePath, 0600)},
+		{"Chtimes", m.Chtimes(testFilePath, time.Now(), time.Now())},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, syscall.EROFS) {
+			t.Errorf("%s: got: `%v', want: `%v'", c.name, c.err, syscall.EROFS)
+		}
+	}
+
+	// reading still works
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	fd, err := m.OpenFile(testFilePath, os.O_RDONLY|os.O_CREATE, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if _, err := io.ReadAll(fd); err != nil {
+		t.Error(err)
+	}
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Error(err)
+	}
+	if _, err := m.ReadDir(testFileDir); err != nil {
+		t.Error(err)
+	}
+	if err := m.MkdirAll(testFileDir, 0755); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadOnlyMetadata(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Setxattr(testFilePath, "user.test", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	r := ReadOnly(m)
+	for name, err := range map[string]error{
+		"Chmod":    r.Chmod(testFilePath, 0600),
+		"Chtimes":  r.Chtimes(testFilePath, time.Now(), time.Now()),
+		"Setxattr": r.Setxattr(testFilePath, "user.test", []byte("other")),
+	} {
+		if !errors.Is(err, syscall.EROFS) {
+			t.Errorf("%s: got: `%v', want: `%v'", name, err, syscall.EROFS)
+		}
+	}
+	fi, err := r.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0644 {
+		t.Errorf("got: `%v', want: `%v'", fi.Mode(), fs.FileMode(0644))
+	}
+	bs, err := r.Getxattr(testFilePath, "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "value" {
+		t.Errorf("got: `%s', want: `value'", bs)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.OpenFile(testFilePath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	m.Seal()
+	for name, err := range map[string]error{
+		"Write": func() error {
+			_, err := fd.Write([]byte(testContent))
+			return err
+		}(),
+		"Chmod":    m.Chmod(testFilePath, 0600),
+		"Chtimes":  m.Chtimes(testFilePath, time.Now(), time.Now()),
+		"Setxattr": m.Setxattr(testFilePath, "user.test", []byte("value")),
+	} {
+		if !errors.Is(err, syscall.EROFS) {
+			t.Errorf("%s: got: `%v', want: `%v'", name, err, syscall.EROFS)
+		}
+	}
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Error(err)
+	}
+	if _, err := m.Getxattr(testFilePath, "user.test"); !errors.Is(err, syscall.ENODATA) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENODATA)
+	}
+}
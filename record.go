@@ -0,0 +1,42 @@
+package ffs
+
+import "golang.org/x/exp/slices"
+
+// Operation is an operation recorded by Record. Op names the system call the
+// operation amounts to on a real file system: "open", "stat", "lstat", or
+// "readdir". Path is the absolute path it was called on.
+type Operation struct {
+	Op   string
+	Path string
+}
+
+// Record starts recording operations, discarding those recorded so far.
+// Only the operations that would be expensive on a remote file system are
+// recorded: opening files (Open and OpenFile), describing them (Stat, Lstat,
+// and DirEntry.Info), and listing directories (ReadDir, and the directories
+// visited by WalkDir and its variants).
+// DirEntry.Type and DirEntry.IsDir are answered by the directory listing,
+// so they don't amount to a stat.
+func (m *FakeFileSystem) Record() {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+	m.recording = true
+	m.operations = nil
+}
+
+// Operations returns the operations recorded since the last call to Record,
+// in the order they happened.
+func (m *FakeFileSystem) Operations() []Operation {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+	return slices.Clone(m.operations)
+}
+
+// record records the operation op on the absolute path, if recording.
+func (m *FakeFileSystem) record(op, path string) {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+	if m.recording {
+		m.operations = append(m.operations, Operation{op, path})
+	}
+}
@@ -0,0 +1,117 @@
+package ffs
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func countOps(ops []Operation, op string) int {
+	n := 0
+	for _, o := range ops {
+		if o.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRecordTypeIsNotStat(t *testing.T) {
+	m := MockFS(
+		WithFile("/dir/a.txt", []byte(testContent)),
+		WithFile("/dir/b.txt", []byte(testContent)),
+		WithFile("/dir/sub/c.txt", []byte(testContent)),
+	)
+
+	m.Record()
+	entries, err := m.ReadDir("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		_ = e.Type()
+		_ = e.IsDir()
+		_ = e.Name()
+	}
+	if err := m.WalkDir("/dir", func(path string, d fs.DirEntry, err error) error {
+		_ = d.Type()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ops := m.Operations()
+	if n := countOps(ops, "stat"); n != 0 {
+		t.Errorf("Type recorded %d stats: %v", n, ops)
+	}
+	if n := countOps(ops, "readdir"); n != 3 {
+		t.Errorf("got %d readdirs, want: 3: %v", n, ops)
+	}
+
+	m.Record()
+	for _, e := range entries {
+		if _, err := e.Info(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ops = m.Operations()
+	want := []Operation{
+		{"stat", "/dir/a.txt"},
+		{"stat", "/dir/b.txt"},
+		{"stat", "/dir/sub"},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("got: `%v', want: `%v'", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("got: `%v', want: `%v'", ops[i], want[i])
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if ops := m.Operations(); len(ops) != 0 {
+		t.Errorf("recorded before Record: %v", ops)
+	}
+	m.Record()
+	if _, err := m.Stat(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	ops := m.Operations()
+	want := []Operation{
+		{"stat", testFilePath},
+		{"open", testFilePath},
+	}
+	if len(ops) != len(want) || ops[0] != want[0] || ops[1] != want[1] {
+		t.Errorf("got: `%v', want: `%v'", ops, want)
+	}
+}
+
+func TestRecordWalkFileRoot(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/f", []byte(testContent)),
+	)
+	m.Record()
+	if err := m.WalkDir("/a/f", func(path string, d fs.DirEntry, err error) error {
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ops := m.Operations()
+	if n := countOps(ops, "readdir"); n != 0 {
+		t.Errorf("walking a file recorded %d readdirs: %v", n, ops)
+	}
+	if len(ops) != 1 || ops[0] != (Operation{"lstat", "/a/f"}) {
+		t.Errorf("got: `%v', want: `[{lstat /a/f}]'", ops)
+	}
+}
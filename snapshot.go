@@ -0,0 +1,35 @@
+package ffs
+
+// Snapshot is a checkpoint of the tree of a FakeFileSystem, see
+// FakeFileSystem.Snapshot.
+type Snapshot struct {
+	fsys *FakeFileSystem
+}
+
+// Snapshot captures the current state of the tree: the directory structure,
+// the contents, modes and timestamps of the files, and the working
+// directory. Pass it to Restore to roll back to that state.
+func (m *FakeFileSystem) Snapshot() Snapshot {
+	return Snapshot{m.Clone()}
+}
+
+// Restore returns the tree to the state captured by s, discarding
+// everything created, changed, or removed since. A snapshot can be restored
+// any number of times.
+// Descriptors that are open at the time of the restore keep referring to
+// the files they were opened on, which are no longer part of the tree, as if
+// those files had been removed.
+func (m *FakeFileSystem) Restore(s Snapshot) {
+	c := s.fsys.Clone()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.contents {
+		m.unlink(f)
+	}
+	m.root, m.parent = c.root, c.parent
+	m.contents = c.contents
+	m.cwd = c.cwd
+	if m.readCache != nil {
+		m.readCache = map[string]readCacheEntry{}
+	}
+}
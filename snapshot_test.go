@@ -0,0 +1,91 @@
+package ffs
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/home/other/file.txt", []byte(testContent)),
+	)
+	before := m.String()
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := m.Snapshot()
+
+	for i := 0; i < 2; i++ {
+		if err := m.WriteFile(testFilePath, []byte("changed"), testPerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Chmod(testFilePath, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Chtimes(testFilePath, time.Time{}, time.Unix(0, 0)); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.RemoveAll("/home/other"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.MkdirAll("/new/dir", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.WriteFile("/new/dir/file", []byte(testContent), testPerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Rename(testFileDir, "/moved"); err != nil {
+			t.Fatal(err)
+		}
+		if m.String() == before {
+			t.Fatal("mutations had no effect")
+		}
+
+		// restoring the same snapshot more than once works
+		m.Restore(s)
+		if got := m.String(); got != before {
+			t.Errorf("got: `%s', want: `%s'", got, before)
+		}
+		restored, err := m.Stat(testFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.Mode() != fi.Mode() {
+			t.Errorf("got: `%v', want: `%v'", restored.Mode(), fi.Mode())
+		}
+		if !restored.ModTime().Equal(fi.ModTime()) {
+			t.Errorf("got: `%v', want: `%v'", restored.ModTime(), fi.ModTime())
+		}
+		if _, err := m.Stat("/new"); !os.IsNotExist(err) {
+			t.Errorf("got: `%v', want: not exist", err)
+		}
+	}
+}
+
+func TestRestoreOpenDescriptor(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	s := m.Snapshot()
+	fd, err := m.OpenFile(testFilePath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Restore(s)
+	if _, err := fd.Write([]byte("XXXX")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
@@ -0,0 +1,58 @@
+package ffs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// stat describes f the way stat(2) does, so that code type-asserting Sys() to
+// *syscall.Stat_t works.
+func (f *FakeFile) stat() any {
+	st := &syscall.Stat_t{
+		Mode: unixMode(f.fileMode()),
+		Size: f.size(),
+		Atim: syscall.NsecToTimespec(f.lastAccess.UnixNano()),
+		Mtim: syscall.NsecToTimespec(f.lastMod.UnixNano()),
+		Ctim: syscall.NsecToTimespec(f.lastChange.UnixNano()),
+		Uid:  uint32(f.uid),
+		Gid:  uint32(f.gid),
+	}
+	setUint(&st.Nlink, f.nlink)
+	return st
+}
+
+// setUint assigns v to *p, whose type differs between architectures.
+func setUint[T ~uint32 | ~uint64](p *T, v int) {
+	*p = T(v)
+}
+
+// unixMode converts mode to the st_mode bits of stat(2).
+func unixMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	switch mode.Type() {
+	case fs.ModeDir:
+		m |= syscall.S_IFDIR
+	case fs.ModeSymlink:
+		m |= syscall.S_IFLNK
+	case fs.ModeNamedPipe:
+		m |= syscall.S_IFIFO
+	case fs.ModeSocket:
+		m |= syscall.S_IFSOCK
+	case fs.ModeDevice:
+		m |= syscall.S_IFBLK
+	case fs.ModeDevice | fs.ModeCharDevice:
+		m |= syscall.S_IFCHR
+	default:
+		m |= syscall.S_IFREG
+	}
+	if mode&fs.ModeSetuid != 0 {
+		m |= syscall.S_ISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= syscall.S_ISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= syscall.S_ISVTX
+	}
+	return m
+}
@@ -0,0 +1,202 @@
+package ffs
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// ctime extracts the change time from the *syscall.Stat_t returned by Sys.
+func ctime(fi fs.FileInfo) time.Time {
+	st := fi.Sys().(*syscall.Stat_t)
+	return time.Unix(st.Ctim.Unix())
+}
+
+func TestChangeTime(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+
+	Time = func() time.Time { return t0 }
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+
+	// metadata change advances ctime, but not mtime
+	Time = func() time.Time { return t1 }
+	const newPath = "/Classified/Renamed.txt"
+	err := m.Rename(testFilePath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(t0) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), t0)
+	}
+	if ct := ctime(fi); !ct.Equal(t1) {
+		t.Errorf("got: `%v', want: `%v'", ct, t1)
+	}
+
+	// content change advances both
+	Time = func() time.Time { return t2 }
+	fd, err := m.OpenFile(newPath, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fd.Write([]byte("1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(t2) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), t2)
+	}
+	if ct := ctime(fi); !ct.Equal(t2) {
+		t.Errorf("got: `%v', want: `%v'", ct, t2)
+	}
+}
+
+func TestChmodChangeTime(t *testing.T) {
+	defer func(orig func() time.Time) { Time = orig }(Time)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	Time = func() time.Time { return t0 }
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+
+	Time = func() time.Time { return t1 }
+	if err := m.Chmod(testFilePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(t0) {
+		t.Errorf("got: `%v', want: `%v'", fi.ModTime(), t0)
+	}
+	if ct := ctime(fi); !ct.Equal(t1) {
+		t.Errorf("got: `%v', want: `%v'", ct, t1)
+	}
+	if st := fi.Sys().(*syscall.Stat_t); st.Mode != syscall.S_IFREG|0600 {
+		t.Errorf("got: %o, want: %o", st.Mode, syscall.S_IFREG|0600)
+	}
+}
+
+// atime extracts the access time from the *syscall.Stat_t returned by Sys.
+func atime(fi fs.FileInfo) time.Time {
+	st := fi.Sys().(*syscall.Stat_t)
+	return time.Unix(st.Atim.Unix())
+}
+
+func TestChtimesAccessTime(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := m.Chtimes(testFilePath, at, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atime(fi); !got.Equal(at) {
+		t.Errorf("got: `%v', want: `%v'", got, at)
+	}
+}
+
+func TestStatSys(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fi, err := m.Stat(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Fatalf("got: %T, want: *syscall.Stat_t", fi.Sys())
+	}
+	if want := syscall.S_IFREG | uint32(fi.Mode().Perm()); st.Mode != want {
+		t.Errorf("got: %o, want: %o", st.Mode, want)
+	}
+	if st.Size != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", st.Size, len(testContent))
+	}
+	if mtime := time.Unix(st.Mtim.Unix()); !mtime.Equal(fi.ModTime()) {
+		t.Errorf("got: `%v', want: `%v'", mtime, fi.ModTime())
+	}
+
+	fi, err = m.Stat(testFileDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st = fi.Sys().(*syscall.Stat_t)
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		t.Errorf("got: %o, want a directory", st.Mode)
+	}
+}
+
+func TestLinkCount(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	nlink := func(path string) uint64 {
+		t.Helper()
+		fi, err := m.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint64(fi.Sys().(*syscall.Stat_t).Nlink)
+	}
+	if n := nlink(testFilePath); n != 1 {
+		t.Errorf("got: %d, want: 1", n)
+	}
+	if err := m.Link(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if n := nlink(testFilePath); n != 2 {
+		t.Errorf("got: %d, want: 2", n)
+	}
+	if err := m.Remove("/link"); err != nil {
+		t.Fatal(err)
+	}
+	if n := nlink(testFilePath); n != 1 {
+		t.Errorf("got: %d, want: 1", n)
+	}
+}
+
+func TestStatRemovedLinkCount(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	fd, err := m.Open(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	if err := m.Remove(testFilePath); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fi.Sys().(*syscall.Stat_t).Nlink; n != 0 {
+		t.Errorf("got: %d, want: 0", n)
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+}
@@ -0,0 +1,374 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// subFS is a view of a FakeFileSystem rooted at one of its directories, see
+// Sub.
+type subFS struct {
+	m   *FakeFileSystem
+	dir string // absolute path of the root in m
+}
+
+var _ FileSystem = (*subFS)(nil)
+
+// Sub returns a view of the file system rooted at dir, like fs.Sub: "/" of
+// the returned file system is dir, and relative paths are resolved against
+// it. Paths escaping the view through ".." are rejected with EINVAL.
+// Absolute symlink targets are relative to the view too, but a symlink
+// created outside of the view may still point out of it.
+// The names of descriptors (File.Name) are the paths in the underlying file
+// system.
+func (m *FakeFileSystem) Sub(dir string) (FileSystem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, err := m.lookup(dir)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "sub",
+			Path: dir,
+			Err:  err,
+		}
+	}
+	if !f.isDir {
+		return nil, &os.PathError{
+			Op:   "sub",
+			Path: dir,
+			Err:  syscall.ENOTDIR,
+		}
+	}
+	return &subFS{m, f.path}, nil
+}
+
+// full translates a path of the view to the underlying file system.
+func (s *subFS) full(op, path string) (string, error) {
+	full := filepath.Join(s.dir, path)
+	if full != s.dir && !strings.HasPrefix(full, s.dir+"/") && s.dir != "/" {
+		return "", &os.PathError{
+			Op:   op,
+			Path: path,
+			Err:  syscall.EINVAL,
+		}
+	}
+	return full, nil
+}
+
+// short translates a path of the underlying file system to the view.
+func (s *subFS) short(full string) string {
+	if s.dir == "/" {
+		return full
+	}
+	if full == s.dir {
+		return "/"
+	}
+	return strings.TrimPrefix(full, s.dir)
+}
+
+func (s *subFS) Create(path string) (File, error) {
+	full, err := s.full("open", path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := s.m.Create(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return fd, nil
+}
+
+func (s *subFS) Open(path string) (File, error) {
+	full, err := s.full("open", path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := s.m.Open(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return fd, nil
+}
+
+func (s *subFS) Stat(path string) (fs.FileInfo, error) {
+	full, err := s.full("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.m.Stat(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return fi, nil
+}
+
+func (s *subFS) Lstat(path string) (fs.FileInfo, error) {
+	full, err := s.full("lstat", path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.m.Lstat(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return fi, nil
+}
+
+func (s *subFS) OpenFile(path string, flag int, perm fs.FileMode) (File, error) {
+	full, err := s.full("open", path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := s.m.OpenFile(full, flag, perm)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return fd, nil
+}
+
+func (s *subFS) Mkdir(path string, perm fs.FileMode) error {
+	full, err := s.full("mkdir", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.Mkdir(full, perm), path)
+}
+
+func (s *subFS) MkdirAll(path string, perm fs.FileMode) error {
+	full, err := s.full("mkdir", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.MkdirAll(full, perm), path)
+}
+
+func (s *subFS) ReadDir(path string) ([]fs.DirEntry, error) {
+	full, err := s.full("open", path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.m.ReadDir(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return entries, nil
+}
+
+func (s *subFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	full, err := s.full("lstat", root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	return s.m.WalkDir(full, func(path string, d fs.DirEntry, err error) error {
+		rel, relErr := filepath.Rel(full, path)
+		if relErr != nil {
+			return relErr
+		}
+		path = filepath.Join(root, rel)
+		if err != nil {
+			err = relabel(err, path)
+		}
+		return fn(path, d, err)
+	})
+}
+
+func (s *subFS) Truncate(path string, size int64) error {
+	full, err := s.full("truncate", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.Truncate(full, size), path)
+}
+
+func (s *subFS) ReadFile(path string) ([]byte, error) {
+	full, err := s.full("open", path)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := s.m.ReadFile(full)
+	if err != nil {
+		return nil, relabel(err, path)
+	}
+	return bs, nil
+}
+
+func (s *subFS) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	full, err := s.full("open", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.WriteFile(full, data, perm), path)
+}
+
+func (s *subFS) Remove(path string) error {
+	full, err := s.full("remove", path)
+	if err != nil {
+		return err
+	}
+	if full == s.dir {
+		// the root of the view can't be removed, just like "/"
+		return &os.PathError{
+			Op:   "remove",
+			Path: path,
+			Err:  syscall.EPERM,
+		}
+	}
+	return relabel(s.m.Remove(full), path)
+}
+
+func (s *subFS) RemoveAll(path string) error {
+	full, err := s.full("remove", path)
+	if err != nil {
+		return err
+	}
+	if full == s.dir {
+		return &os.PathError{
+			Op:   "remove",
+			Path: path,
+			Err:  syscall.EPERM,
+		}
+	}
+	return relabel(s.m.RemoveAll(full), path)
+}
+
+// relink makes a *os.LinkError returned for the full paths refer to the
+// paths of the view instead.
+func relink(err error, oldname, newname string) error {
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &os.LinkError{
+			Op:  le.Op,
+			Old: oldname,
+			New: newname,
+			Err: le.Err,
+		}
+	}
+	return err
+}
+
+// linkPaths translates both paths of a link operation to the underlying
+// file system.
+func (s *subFS) linkPaths(op, oldname, newname string) (string, string, error) {
+	fullOld, errOld := s.full(op, oldname)
+	fullNew, errNew := s.full(op, newname)
+	if errOld != nil || errNew != nil {
+		return "", "", &os.LinkError{
+			Op:  op,
+			Old: oldname,
+			New: newname,
+			Err: syscall.EINVAL,
+		}
+	}
+	return fullOld, fullNew, nil
+}
+
+func (s *subFS) Rename(oldpath, newpath string) error {
+	fullOld, fullNew, err := s.linkPaths("rename", oldpath, newpath)
+	if err != nil {
+		return err
+	}
+	return relink(s.m.Rename(fullOld, fullNew), oldpath, newpath)
+}
+
+func (s *subFS) Link(oldname, newname string) error {
+	fullOld, fullNew, err := s.linkPaths("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return relink(s.m.Link(fullOld, fullNew), oldname, newname)
+}
+
+func (s *subFS) Symlink(oldname, newname string) error {
+	target := oldname
+	if filepath.IsAbs(oldname) {
+		target = filepath.Join(s.dir, oldname)
+	}
+	_, fullNew, err := s.linkPaths("symlink", "/", newname)
+	if err != nil {
+		return relink(err, oldname, newname)
+	}
+	return relink(s.m.Symlink(target, fullNew), oldname, newname)
+}
+
+func (s *subFS) Readlink(name string) (string, error) {
+	full, err := s.full("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := s.m.Readlink(full)
+	if err != nil {
+		return "", relabel(err, name)
+	}
+	if filepath.IsAbs(target) {
+		target = s.short(target)
+	}
+	return target, nil
+}
+
+func (s *subFS) Chmod(path string, mode fs.FileMode) error {
+	full, err := s.full("chmod", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.Chmod(full, mode), path)
+}
+
+func (s *subFS) Chtimes(path string, atime, mtime time.Time) error {
+	full, err := s.full("chtimes", path)
+	if err != nil {
+		return err
+	}
+	return relabel(s.m.Chtimes(full, atime, mtime), path)
+}
+
+func (s *subFS) CreateTemp(dir, pattern string) (File, error) {
+	if dir == "" {
+		dir = TempDir
+	}
+	full, err := s.full("open", dir)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := s.m.CreateTemp(full, pattern)
+	if err != nil {
+		return nil, relabel(err, filepath.Join(dir, pattern))
+	}
+	return fd, nil
+}
+
+func (s *subFS) MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = TempDir
+	}
+	full, err := s.full("mkdir", dir)
+	if err != nil {
+		return "", err
+	}
+	name, err := s.m.MkdirTemp(full, pattern)
+	if err != nil {
+		return "", relabel(err, filepath.Join(dir, pattern))
+	}
+	return filepath.Join(dir, filepath.Base(name)), nil
+}
+
+func (s *subFS) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	matches, err := s.m.Glob(filepath.Join(s.dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i] = s.short(matches[i])
+		if !filepath.IsAbs(pattern) {
+			matches[i] = strings.TrimPrefix(matches[i], "/")
+		}
+	}
+	return matches, nil
+}
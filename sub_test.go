@@ -0,0 +1,117 @@
+package ffs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestSub(t *testing.T) {
+	m := MockFS(
+		WithFile("/app/config.yaml", []byte(testContent)),
+		WithFile("/app/data/db", []byte("")),
+		WithFile("/secret", []byte("")),
+	)
+	sub, err := m.Sub("/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/config.yaml", "config.yaml", "data/../config.yaml"} {
+		bs, err := sub.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := m.ReadFile("/app/config.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != string(want) {
+			t.Errorf("%s: got: `%s', want: `%s'", path, bs, want)
+		}
+	}
+
+	if err := sub.WriteFile("/new", []byte(testContent), testPerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("/app/new"); err != nil {
+		t.Error(err)
+	}
+	if err := sub.Symlink("/config.yaml", "/link"); err != nil {
+		t.Fatal(err)
+	}
+	target, err := sub.Readlink("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "/config.yaml" {
+		t.Errorf("got: `%s', want: `/config.yaml'", target)
+	}
+	if _, err := sub.ReadFile("/link"); err != nil {
+		t.Error(err)
+	}
+
+	var walked []string
+	err = sub.WalkDir("/", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"/", "/config.yaml", "/data", "/data/db", "/link", "/new"}
+	if !slices.Equal(walked, expected) {
+		t.Errorf("got: `%v', want: `%v'", walked, expected)
+	}
+	matches, err := sub.Glob("/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(matches, []string{"/config.yaml"}) {
+		t.Errorf("got: `%v', want: `[/config.yaml]'", matches)
+	}
+}
+
+func TestSubEscape(t *testing.T) {
+	m := MockFS(
+		WithFile("/app/config.yaml", []byte(testContent)),
+		WithFile("/secret", []byte("")),
+	)
+	sub, err := m.Sub("/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"../secret", "/../secret", "data/../../secret"} {
+		if _, err := sub.ReadFile(path); !errors.Is(err, syscall.EINVAL) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.EINVAL)
+		}
+	}
+	if err := sub.Rename("/config.yaml", "../config.yaml"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EINVAL)
+	}
+	if err := sub.RemoveAll("/"); !errors.Is(err, syscall.EPERM) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EPERM)
+	}
+
+	// errors refer to the paths of the view
+	_, err = sub.Stat("/missing")
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "/missing" {
+		t.Errorf("got: `%v', want: a *os.PathError for `/missing'", err)
+	}
+}
+
+func TestSubNotDir(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if _, err := m.Sub(testFilePath); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOTDIR)
+	}
+	if _, err := m.Sub("/missing"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
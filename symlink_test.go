@@ -0,0 +1,563 @@
+package ffs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSymlink(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink(testFilePath, "/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := m.Readlink("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != testFilePath {
+		t.Errorf("got: `%s', want: `%s'", target, testFilePath)
+	}
+
+	fd, err := m.Open("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	fi, err := m.Stat("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != testFileName {
+		t.Errorf("got: `%s', want: `%s'", fi.Name(), testFileName)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		t.Error("Stat must follow the symlink")
+	}
+}
+
+func TestSymlinkRelative(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink("../Classified", "/a/link")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	err = m.Mkdir("/a", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Symlink("../Classified", "/a/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// symlink as intermediate path component
+	bs, err := m.ReadFile("/a/link/" + testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestSymlinkWriteThrough(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink(testFilePath, "/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const newContent = "Giraffe > Greif"
+	err = m.WriteFile("/link", []byte(newContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != newContent {
+		t.Errorf("got: `%s', want: `%s'", bs, newContent)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	m := MockFS()
+	err := m.Symlink("/b", "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Symlink("/a", "/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Stat("/a")
+	if !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ELOOP)
+	}
+}
+
+func TestSymlinkRemove(t *testing.T) {
+	for _, remove := range []struct {
+		name string
+		fn   func(m *FakeFileSystem, path string) error
+	}{
+		{"Remove", (*FakeFileSystem).Remove},
+		{"RemoveAll", (*FakeFileSystem).RemoveAll},
+	} {
+		t.Run(remove.name, func(t *testing.T) {
+			m := MockFS(
+				WithFile(testFilePath, []byte(testContent)),
+			)
+			if err := m.Symlink(testFilePath, "/link"); err != nil {
+				t.Fatal(err)
+			}
+			if err := remove.fn(m, "/link"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Lstat("/link"); !errors.Is(err, syscall.ENOENT) {
+				t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+			}
+			bs, err := m.ReadFile(testFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(bs) != testContent {
+				t.Errorf("got: `%s', want: `%s'", bs, testContent)
+			}
+
+			// a link to a directory is removed, not the directory's contents
+			if err := m.Symlink(filepath.Dir(testFilePath), "/dirlink"); err != nil {
+				t.Fatal(err)
+			}
+			if err := remove.fn(m, "/dirlink"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Lstat("/dirlink"); !errors.Is(err, syscall.ENOENT) {
+				t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+			}
+			if _, err := m.Stat(testFilePath); err != nil {
+				t.Errorf("got: `%v', want: `<nil>'", err)
+			}
+		})
+	}
+}
+
+func TestSymlinkErrors(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink("/anywhere", testFilePath)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("got: `%v', want: *os.LinkError", err)
+	}
+	if !errors.Is(err, syscall.EEXIST) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EEXIST)
+	}
+	_, err = m.Readlink(testFilePath)
+	if !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EINVAL)
+	}
+	_, err = m.Readlink("/missing")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	// dangling link
+	err = m.Symlink("/missing", "/dangling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Stat("/dangling")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestLstat(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink(testFilePath, "/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lfi, err := m.Lstat("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := m.Stat("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lfi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("got: %v, want a symlink", lfi.Mode())
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("got: %v, want a regular file", fi.Mode())
+	}
+	if lfi.Name() != "link" {
+		t.Errorf("got: `%s', want: `link'", lfi.Name())
+	}
+	if lfi.Size() != int64(len(testFilePath)) {
+		t.Errorf("got: %d, want: %d", lfi.Size(), len(testFilePath))
+	}
+	if fi.Size() != int64(len(testContent)) {
+		t.Errorf("got: %d, want: %d", fi.Size(), len(testContent))
+	}
+
+	// a dangling link can still be inspected
+	err = m.Symlink("/missing", "/dangling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Lstat("/dangling")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = m.Lstat("/missing")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestSymlinkDirEntryType(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink(testFileName, "/Classified/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// resolving a link to itself fails with ELOOP, listing it must not
+	err = m.Symlink("self", "/Classified/self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]fs.FileMode{}
+	err = m.WalkDirDepth(testFileDir, 1, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		types[path] = d.Type()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/Classified/link", "/Classified/self"} {
+		if types[path]&fs.ModeSymlink == 0 {
+			t.Errorf("%s: got: %v, want a symlink", path, types[path])
+		}
+	}
+	if types[testFilePath] != 0 {
+		t.Errorf("%s: got: %v, want a regular file", testFilePath, types[testFilePath])
+	}
+}
+
+func TestSymlinkParentDirectory(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	err := m.Symlink(testFileDir, "/linkdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := m.Create("/linkdir/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	err = m.WriteFile("/linkdir/y", []byte(testContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Mkdir("/linkdir/z", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.MkdirAll("/linkdir/z/w", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Symlink("y", "/linkdir/l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// everything ends up in the link's target
+	for _, path := range []string{"/Classified/x", "/Classified/y", "/Classified/z/w", "/Classified/l"} {
+		_, err := m.Lstat(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	err = m.Rename("/linkdir/x", "/linkdir/v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Remove("/linkdir/v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/Classified/x", "/Classified/v"} {
+		_, err := m.Lstat(path)
+		if !errors.Is(err, syscall.ENOENT) {
+			t.Errorf("%s: got: `%v', want: `%v'", path, err, syscall.ENOENT)
+		}
+	}
+}
+
+func TestSymlinkDanglingWriteThrough(t *testing.T) {
+	m := MockFS(
+		WithDirectory(testFileDir),
+	)
+	err := m.Symlink(testFilePath, "/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.WriteFile("/link", []byte(testContent), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+	fi, err := m.Lstat("/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("got: %v, the link must remain a symlink", fi.Mode())
+	}
+
+	const newPath = "/Classified/New.txt"
+	err = m.Symlink("New.txt", "/Classified/newlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.OpenFile("/Classified/newlink", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if !errors.Is(err, syscall.EEXIST) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.EEXIST)
+	}
+	fd, err := m.Create("/Classified/newlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	_, err = fd.Write([]byte(testContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, err = m.ReadFile(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != testContent {
+		t.Errorf("got: `%s', want: `%s'", bs, testContent)
+	}
+}
+
+func TestSymlinkIntermediateErrno(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+		WithFile("/dir/child", []byte(testContent)),
+	)
+	links := map[string]string{
+		"/tomissing": "/missing",
+		"/tofile":    testFilePath,
+		"/todir":     "/dir",
+		"/loop":      "/loop",
+	}
+	for link, target := range links {
+		if err := m.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		path string
+		want error // nil means Stat succeeds
+	}{
+		{"/tomissing/child", syscall.ENOENT},
+		{"/tofile/child", syscall.ENOTDIR},
+		{"/todir/child", nil},
+		{"/todir/missing", syscall.ENOENT},
+		{"/loop/child", syscall.ELOOP},
+	}
+	for _, c := range cases {
+		for _, op := range []struct {
+			name string
+			stat func(string) (fs.FileInfo, error)
+		}{{"stat", m.Stat}, {"lstat", m.Lstat}} {
+			_, err := op.stat(c.path)
+			if c.want == nil {
+				if err != nil {
+					t.Errorf("%s %s: got: `%v', want: <nil>", op.name, c.path, err)
+				}
+				continue
+			}
+			if !errors.Is(err, c.want) {
+				t.Errorf("%s %s: got: `%v', want: `%v'", op.name, c.path, err, c.want)
+			}
+		}
+	}
+}
+
+func TestWalkDirFollow(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/file", []byte(testContent)),
+		WithFile("/b/1", []byte(testContent)),
+		WithFile("/b/2/3", []byte(testContent)),
+	)
+	for link, target := range map[string]string{
+		"/a/dirlink":  "../b",
+		"/a/filelink": "file",
+		"/a/dangling": "/missing",
+	} {
+		if err := m.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []struct {
+		path string
+		typ  fs.FileMode
+	}{
+		{"/a", fs.ModeDir},
+		{"/a/dangling", fs.ModeSymlink},
+		{"/a/dirlink", fs.ModeDir},
+		{"/a/dirlink/1", 0},
+		{"/a/dirlink/2", fs.ModeDir},
+		{"/a/dirlink/2/3", 0},
+		{"/a/file", 0},
+		{"/a/filelink", 0},
+	}
+	i := 0
+	err := m.WalkDirFollow("/a", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if i >= len(expected) {
+			t.Errorf("unexpected: `%s'", path)
+			return nil
+		}
+		if path != expected[i].path {
+			t.Errorf("got: `%s', want: `%s'", path, expected[i].path)
+		}
+		if d.Type() != expected[i].typ {
+			t.Errorf("%s: got: %v, want: %v", path, d.Type(), expected[i].typ)
+		}
+		if d.Name() != filepath.Base(path) {
+			t.Errorf("got: `%s', want: `%s'", d.Name(), filepath.Base(path))
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(expected) {
+		t.Errorf("visited %d entries, want: %d", i, len(expected))
+	}
+
+	// without following, the links are reported as such
+	err = m.WalkDir("/a", func(path string, d fs.DirEntry, err error) error {
+		if path == "/a/dirlink/1" {
+			t.Error("WalkDir must not follow symlinks")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalkDirFollowLoop(t *testing.T) {
+	m := MockFS(
+		WithFile("/a/b/file", []byte(testContent)),
+	)
+	err := m.Symlink("/a", "/a/b/up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	visited := 0
+	err = m.WalkDirFollow("/a", func(path string, d fs.DirEntry, err error) error {
+		visited++
+		if visited > 100 {
+			t.Fatal("walk does not terminate")
+		}
+		return err
+	})
+	if !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ELOOP)
+	}
+}
+
+func TestWalkDirSymlinkAncestor(t *testing.T) {
+	m := MockFS(
+		WithFile("/real/dir/f", []byte(testContent)),
+		WithFile("/file", []byte(testContent)),
+	)
+	if err := m.Symlink("/real", "/link"); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	err := m.WalkDir("/link/dir", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/link/dir", "/link/dir/f"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("got: `%v', want: `%v'", paths, want)
+	}
+
+	if err := m.Symlink("/loop", "/loop"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		root string
+		want error
+	}{
+		{"/missing/dir", syscall.ENOENT},
+		{"/file/dir", syscall.ENOTDIR},
+		{"/loop/dir", syscall.ELOOP},
+	} {
+		calls := 0
+		err := m.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+			calls++
+			return err
+		})
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: got: `%v', want: `%v'", c.root, err, c.want)
+		}
+		if calls != 1 {
+			t.Errorf("%s: fn called %d times, want: 1", c.root, calls)
+		}
+	}
+}
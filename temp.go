@@ -0,0 +1,110 @@
+package ffs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// TempDir is the directory CreateTemp and MkdirTemp use if no other
+// directory is given. It's created on first use.
+const TempDir = "/tmp"
+
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// CreateTemp creates a new file in dir, and opens it for reading and writing,
+// like os.CreateTemp.
+// The name is pattern, with the last "*" replaced by a number (or with the
+// number appended, if there's no "*"). Unlike with os.CreateTemp, the
+// numbers are deterministic: they count up from 1 with every call (to either
+// CreateTemp or MkdirTemp), so that tests are reproducible.
+// If dir is empty, TempDir is used.
+func (m *FakeFileSystem) CreateTemp(dir, pattern string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("CreateTemp", "open", filepath.Join(dir, pattern)); err != nil {
+		return nil, err
+	}
+	if err := m.checkWritable("open", filepath.Join(dir, pattern)); err != nil {
+		return nil, err
+	}
+	if err := m.checkOpenFiles(filepath.Join(dir, pattern)); err != nil {
+		return nil, err
+	}
+	prefix, suffix, err := m.tempPattern("createtemp", &dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		name := m.tempName(dir, prefix, suffix)
+		f, err := m.openFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, syscall.EEXIST) {
+			continue
+		}
+		return m.register(f, err)
+	}
+}
+
+// MkdirTemp creates a new directory in dir, and returns its path, like
+// os.MkdirTemp.
+// The directory is named like the files of CreateTemp.
+func (m *FakeFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("MkdirTemp", "mkdir", filepath.Join(dir, pattern)); err != nil {
+		return "", err
+	}
+	if err := m.checkWritable("mkdir", filepath.Join(dir, pattern)); err != nil {
+		return "", err
+	}
+	prefix, suffix, err := m.tempPattern("mkdirtemp", &dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := m.tempName(dir, prefix, suffix)
+		err := m.mkdir(name, 0700)
+		if errors.Is(err, syscall.EEXIST) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+// tempPattern splits pattern into the prefix and suffix around its last "*".
+// An empty *dir is replaced by TempDir, which is created if necessary.
+func (m *FakeFileSystem) tempPattern(op string, dir *string, pattern string) (prefix, suffix string, err error) {
+	if strings.ContainsRune(pattern, filepath.Separator) {
+		return "", "", &os.PathError{
+			Op:   op,
+			Path: pattern,
+			Err:  errPatternHasSeparator,
+		}
+	}
+	if *dir == "" {
+		*dir = TempDir
+		if _, err := m.mkdirAll(TempDir, 0777); err != nil {
+			return "", "", &os.PathError{
+				Op:   op,
+				Path: TempDir,
+				Err:  err,
+			}
+		}
+	}
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return pattern[:i], pattern[i+1:], nil
+	}
+	return pattern, "", nil
+}
+
+// tempName returns the next candidate name for a temporary file or directory.
+func (m *FakeFileSystem) tempName(dir, prefix, suffix string) string {
+	m.tempCount++
+	return filepath.Join(dir, prefix+strconv.FormatUint(m.tempCount, 10)+suffix)
+}
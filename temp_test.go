@@ -0,0 +1,106 @@
+package ffs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCreateTemp(t *testing.T) {
+	m := MockFS(
+		WithDirectory("/work"),
+	)
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		fd, err := m.CreateTemp("/work", "log-*.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fd.Name()
+		if !strings.HasPrefix(name, "log-") || !strings.HasSuffix(name, ".txt") {
+			t.Errorf("got: `%s', want: `log-*.txt'", name)
+		}
+		if seen[name] {
+			t.Errorf("got `%s' twice", name)
+		}
+		seen[name] = true
+		if _, err := fd.Write([]byte(testContent)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fd.Close(); err != nil {
+			t.Fatal(err)
+		}
+		bs, err := m.ReadFile(filepath.Join("/work", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != testContent {
+			t.Errorf("got: `%s', want: `%s'", bs, testContent)
+		}
+	}
+
+	_, err := m.CreateTemp("/missing", "log-*.txt")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+	_, err = m.CreateTemp("/work", "a/b-*")
+	if !errors.Is(err, errPatternHasSeparator) {
+		t.Errorf("got: `%v', want: `%v'", err, errPatternHasSeparator)
+	}
+}
+
+func TestCreateTempDefaultDir(t *testing.T) {
+	m := MockFS()
+	fd, err := m.CreateTemp("", "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	fi, err := fd.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("got: %o, want: %o", fi.Mode().Perm(), 0600)
+	}
+	if _, err := m.Stat(filepath.Join(TempDir, fd.Name())); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMkdirTemp(t *testing.T) {
+	m := MockFS(
+		WithDirectory("/work"),
+	)
+	// existing names are skipped
+	if err := m.Mkdir("/work/build-1", 0755); err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.MkdirTemp("/work", "build-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.MkdirTemp("/work", "build-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a == "/work/build-1" || b == "/work/build-1" {
+		t.Errorf("got: `%s' and `%s', want distinct new directories", a, b)
+	}
+	for _, dir := range []string{a, b} {
+		fi, err := m.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.IsDir() {
+			t.Errorf("%s: expected a directory", dir)
+		}
+	}
+
+	_, err = m.MkdirTemp("/missing", "build-*")
+	if !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
@@ -0,0 +1,44 @@
+package ffs
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// WithTextMode treats all files as text files, normalizing line endings,
+// similar to git's autocrlf.
+// ReadFile converts CRLF line endings to LF, WriteFile converts LF line
+// endings to the platform's convention (see WithLineEnding).
+func WithTextMode() FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.textMode = true
+		if fs.lineEnding == "" {
+			fs.lineEnding = platformLineEnding()
+		}
+	}
+}
+
+// WithLineEnding sets the line ending WriteFile uses in text mode, overriding
+// the platform's convention. Use "\r\n" to simulate Windows.
+func WithLineEnding(eol string) FSOption {
+	return func(fs *FakeFileSystem) {
+		fs.lineEnding = eol
+	}
+}
+
+func platformLineEnding() string {
+	if runtime.GOOS == "windows" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// fromText normalizes CRLF line endings to LF.
+func fromText(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+}
+
+// toText converts LF line endings to eol.
+func toText(data []byte, eol string) []byte {
+	return bytes.ReplaceAll(fromText(data), []byte("\n"), []byte(eol))
+}
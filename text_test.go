@@ -0,0 +1,43 @@
+package ffs
+
+import (
+	"testing"
+)
+
+func TestTextModeWindows(t *testing.T) {
+	m := MockFS(
+		WithTextMode(),
+		WithLineEnding("\r\n"),
+		WithDirectory(testFileDir),
+	)
+	err := m.WriteFile(testFilePath, []byte("a\nb"), testPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := m.contents[testFilePath].bytes
+	if string(stored) != "a\r\nb" {
+		t.Errorf("got: `%q', want: `%q'", stored, "a\r\nb")
+	}
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "a\nb" {
+		t.Errorf("got: `%q', want: `%q'", bs, "a\nb")
+	}
+}
+
+func TestTextModeReadsWindowsFixture(t *testing.T) {
+	m := MockFS(
+		WithTextMode(),
+		WithLineEnding("\n"),
+		WithFile(testFilePath, []byte("a\r\nb\r\n")),
+	)
+	bs, err := m.ReadFile(testFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "a\nb\n" {
+		t.Errorf("got: `%q', want: `%q'", bs, "a\nb\n")
+	}
+}
@@ -0,0 +1,93 @@
+package ffs
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// XattrFileSystem is implemented by file systems that support extended
+// attributes, see xattr(7).
+type XattrFileSystem interface {
+	Setxattr(path, name string, data []byte) error
+	Getxattr(path, name string) ([]byte, error)
+}
+
+var (
+	_ XattrFileSystem = (*FakeFileSystem)(nil)
+	_ XattrFileSystem = (*ReadOnlyFileSystem)(nil)
+)
+
+// Setxattr sets the extended attribute name of the file to data.
+// If the file is a symlink, the attribute is set on its target.
+// Like setxattr(2), this changes the file's ctime, but not its mtime.
+func (m *FakeFileSystem) Setxattr(uncleanedPath, name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.injectedPathError("Setxattr", "setxattr", uncleanedPath); err != nil {
+		return err
+	}
+	if err := m.checkWritable("setxattr", uncleanedPath); err != nil {
+		return err
+	}
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return &os.PathError{
+			Op:   "setxattr",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	if f.xattrs == nil {
+		f.xattrs = map[string][]byte{}
+	}
+	f.xattrs[name] = bytes.Clone(data)
+	f.changed()
+	return nil
+}
+
+// Getxattr returns the value of the extended attribute name of the file, or
+// ENODATA if it isn't set.
+// If the file is a symlink, the attribute of its target is returned.
+func (m *FakeFileSystem) Getxattr(uncleanedPath, name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.injectedPathError("Getxattr", "getxattr", uncleanedPath); err != nil {
+		return nil, err
+	}
+	f, err := m.lookup(uncleanedPath)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "getxattr",
+			Path: uncleanedPath,
+			Err:  err,
+		}
+	}
+	data, ok := f.xattrs[name]
+	if !ok {
+		return nil, &os.PathError{
+			Op:   "getxattr",
+			Path: uncleanedPath,
+			Err:  syscall.ENODATA,
+		}
+	}
+	return bytes.Clone(data), nil
+}
+
+func (r *ReadOnlyFileSystem) Setxattr(path, name string, data []byte) error {
+	return erofs("setxattr", path)
+}
+
+// Getxattr delegates to the wrapped file system, failing with ENOTSUP if it
+// doesn't support extended attributes.
+func (r *ReadOnlyFileSystem) Getxattr(path, name string) ([]byte, error) {
+	x, ok := r.fsys.(XattrFileSystem)
+	if !ok {
+		return nil, &os.PathError{
+			Op:   "getxattr",
+			Path: path,
+			Err:  syscall.ENOTSUP,
+		}
+	}
+	return x.Getxattr(path, name)
+}
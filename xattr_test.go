@@ -0,0 +1,50 @@
+package ffs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestXattr(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if _, err := m.Getxattr(testFilePath, "user.test"); !errors.Is(err, syscall.ENODATA) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENODATA)
+	}
+	value := []byte("value")
+	if err := m.Setxattr(testFilePath, "user.test", value); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'X' // the stored value must not alias the caller's slice
+	bs, err := m.Getxattr(testFilePath, "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "value" {
+		t.Errorf("got: `%s', want: `value'", bs)
+	}
+	if _, err := m.Getxattr("/missing", "user.test"); !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("got: `%v', want: `%v'", err, syscall.ENOENT)
+	}
+}
+
+func TestXattrFollowsSymlink(t *testing.T) {
+	m := MockFS(
+		WithFile(testFilePath, []byte(testContent)),
+	)
+	if err := m.Symlink(testFilePath, "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Setxattr("/link", "user.test", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := m.Getxattr(testFilePath, "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "value" {
+		t.Errorf("got: `%s', want: `value'", bs)
+	}
+}